@@ -0,0 +1,114 @@
+package resolver
+
+import (
+	"sort"
+
+	grpcresolver "google.golang.org/grpc/resolver"
+
+	"google.golang.org/grpc/naming"
+)
+
+// Scheme is the scheme registered by the resolver in grpc (format: marathon:///{NAME})
+const Scheme = "marathon"
+
+// Build creates a grpc resolver given a target. The target endpoint is
+// the service name defined in the marathon application labels.
+func (r *Resolver) Build(target grpcresolver.Target, cc grpcresolver.ClientConn, opts grpcresolver.BuildOptions) (grpcresolver.Resolver, error) {
+	poll, err := newPoll(target.Endpoint, r.marathon, r.opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	w := &watcher{
+		poll:  poll,
+		cc:    cc,
+		addrs: make(map[string]grpcresolver.Address),
+		done:  make(chan struct{}),
+	}
+
+	// Running marathon poll
+	poll.run()
+
+	r.track(poll)
+
+	go w.watch()
+
+	return w, nil
+}
+
+// Scheme returns the scheme supported by the resolver
+func (r *Resolver) Scheme() string {
+	return Scheme
+}
+
+// watcher pushes the poller updates to a grpc client connection
+type watcher struct {
+	poll  *poll
+	cc    grpcresolver.ClientConn
+	addrs map[string]grpcresolver.Address
+	done  chan struct{}
+}
+
+// watch blocks until the watcher is closed and forwards the complete
+// address list to the client connection each time the poller emits
+// updates.
+func (w *watcher) watch() {
+	for {
+		ups, err := w.poll.Next()
+
+		select {
+		case <-w.done:
+			return
+		default:
+		}
+
+		if err != nil {
+			w.cc.ReportError(err)
+			return
+		}
+
+		if len(ups) == 0 {
+			continue
+		}
+
+		for _, up := range ups {
+			switch up.Op {
+			case naming.Add:
+				// The address is kept as is until it is removed
+				// or announced again, the balancers identifying
+				// the addresses by their attributes too.
+				md, _ := up.Metadata.(*Metadata)
+				w.addrs[up.Addr] = NewAddress(up.Addr, md)
+			case naming.Delete:
+				delete(w.addrs, up.Addr)
+			}
+		}
+
+		w.cc.UpdateState(grpcresolver.State{
+			Addresses: w.addresses(),
+		})
+	}
+}
+
+// addresses returns the sorted set of addresses currently resolved
+func (w *watcher) addresses() []grpcresolver.Address {
+	addrs := make([]grpcresolver.Address, 0, len(w.addrs))
+	for _, addr := range w.addrs {
+		addrs = append(addrs, addr)
+	}
+
+	sort.Slice(addrs, func(i, j int) bool {
+		return addrs[i].Addr < addrs[j].Addr
+	})
+
+	return addrs
+}
+
+// ResolveNow is a no-op since the poller monitors marathon continuously
+func (w *watcher) ResolveNow(grpcresolver.ResolveNowOptions) {}
+
+// Close stops the poller and the probes monitoring
+func (w *watcher) Close() {
+	close(w.done)
+	w.poll.Close()
+}
@@ -0,0 +1,188 @@
+package resolver
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/eddyzags/resolver/marathon"
+
+	uuid "github.com/satori/go.uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/unrolled/render"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/balancer/roundrobin"
+	pb "google.golang.org/grpc/examples/helloworld/helloworld"
+	grpcresolver "google.golang.org/grpc/resolver"
+)
+
+type clientConn struct {
+	grpcresolver.ClientConn
+	states chan grpcresolver.State
+}
+
+func (cc *clientConn) UpdateState(state grpcresolver.State) {
+	cc.states <- state
+}
+
+func (cc *clientConn) ReportError(err error) {}
+
+func TestBuildWithoutError(t *testing.T) {
+	assert := assert.New(t)
+
+	grpcServer, addr, err := newGRPCServer()
+	assert.NoError(err, "an unexpected error occured in grpc server instantiation")
+
+	defer grpcServer.Stop()
+
+	key := "RESOLVER_0_NAME"
+	val := "service-test"
+
+	port, err := strconv.ParseInt(strings.Split(addr, ":")[1], 10, 32)
+	assert.NoError(err, "an unexpected error occured in grpc server port parsing")
+
+	apps := []*marathon.Application{
+		{
+			ID: "/test",
+			Labels: &map[string]string{
+				key: val,
+			},
+		},
+	}
+
+	tasks := []*marathon.Task{
+		{
+			ID:    uuid.Must(uuid.NewV4()).String(),
+			AppID: apps[0].ID,
+			Host:  "127.0.0.1",
+			Ports: []int{
+				int(port),
+			},
+		},
+	}
+
+	handler := http.HandlerFunc(func(rw http.ResponseWriter, rq *http.Request) {
+		switch rq.URL.Path {
+		case "/v2/apps":
+			render.New().JSON(rw, http.StatusOK, apps)
+		case "/v2/apps/test/tasks":
+			render.New().JSON(rw, http.StatusOK, map[string]interface{}{"tasks": tasks})
+		default:
+			render.New().JSON(rw, http.StatusOK, nil)
+		}
+	})
+
+	ts := httptest.NewServer(handler)
+	defer ts.Close()
+
+	resolver, err := New(ts.URL)
+	assert.NoError(err, "an unexpected error occured in resolver instantiation")
+
+	assert.Equal(Scheme, resolver.Scheme(), "the schemes should be equals")
+
+	cc := &clientConn{states: make(chan grpcresolver.State, 1)}
+
+	r, err := resolver.Build(grpcresolver.Target{Scheme: Scheme, Endpoint: val}, cc, grpcresolver.BuildOptions{})
+	assert.NoError(err, "an unexpected error occured in resolver build")
+	defer r.Close()
+
+	select {
+	case state := <-cc.states:
+		assert.Equal(1, len(state.Addresses), "the number of addresses should be 1")
+		assert.Equal(addr, state.Addresses[0].Addr, "the addresses should be equals")
+		assert.Equal(&Metadata{AppID: apps[0].ID, Weight: 1}, AddressMetadata(state.Addresses[0]), "the metadata should be equals")
+	case <-time.After(5 * time.Second):
+		t.Fatal("no state was pushed to the client connection")
+	}
+}
+
+func TestBuildWithErrorOnLabelNotFound(t *testing.T) {
+	assert := assert.New(t)
+
+	handler := http.HandlerFunc(func(rw http.ResponseWriter, rq *http.Request) {
+		render.New().JSON(rw, http.StatusOK, []*marathon.Application{})
+	})
+
+	ts := httptest.NewServer(handler)
+	defer ts.Close()
+
+	resolver, err := New(ts.URL)
+	assert.NoError(err, "an unexpected error occured in resolver instantiation")
+
+	cc := &clientConn{states: make(chan grpcresolver.State, 1)}
+
+	r, err := resolver.Build(grpcresolver.Target{Scheme: Scheme, Endpoint: "service-test"}, cc, grpcresolver.BuildOptions{})
+	assert.Error(err, "an error was expected in resolver build")
+
+	assert.Nil(r, "the resolver should be nil")
+}
+
+func TestBuildDialWithoutError(t *testing.T) {
+	assert := assert.New(t)
+
+	grpcServer, addr, err := newGRPCServer()
+	assert.NoError(err, "an unexpected error occured in grpc server instantiation")
+
+	defer grpcServer.Stop()
+
+	key := "RESOLVER_0_NAME"
+	val := "service-test"
+
+	port, err := strconv.ParseInt(strings.Split(addr, ":")[1], 10, 32)
+	assert.NoError(err, "an unexpected error occured in grpc server port parsing")
+
+	apps := []*marathon.Application{
+		{
+			ID: "/test",
+			Labels: &map[string]string{
+				key: val,
+			},
+		},
+	}
+
+	tasks := []*marathon.Task{
+		{
+			ID:    uuid.Must(uuid.NewV4()).String(),
+			AppID: apps[0].ID,
+			Host:  "127.0.0.1",
+			Ports: []int{
+				int(port),
+			},
+		},
+	}
+
+	handler := http.HandlerFunc(func(rw http.ResponseWriter, rq *http.Request) {
+		switch rq.URL.Path {
+		case "/v2/apps":
+			render.New().JSON(rw, http.StatusOK, apps)
+		case "/v2/apps/test/tasks":
+			render.New().JSON(rw, http.StatusOK, map[string]interface{}{"tasks": tasks})
+		default:
+			render.New().JSON(rw, http.StatusOK, nil)
+		}
+	})
+
+	ts := httptest.NewServer(handler)
+	defer ts.Close()
+
+	resolver, err := New(ts.URL)
+	assert.NoError(err, "an unexpected error occured in resolver instantiation")
+
+	grpcresolver.Register(resolver)
+
+	conn, err := grpc.Dial(Scheme+":///"+val, grpc.WithInsecure(), grpc.WithBalancerName(roundrobin.Name))
+	assert.NoError(err, "an unexpected error occured in grpc dial")
+	defer conn.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	r, err := pb.NewGreeterClient(conn).SayHello(ctx, &pb.HelloRequest{Name: "user1"}, grpc.WaitForReady(true))
+	assert.NoError(err, "an unexpected error occured in say hello")
+
+	assert.Equal("Hello user1", r.GetMessage(), "the messages should be equals")
+}
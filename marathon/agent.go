@@ -0,0 +1,197 @@
+package marathon
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// defaultAgentCacheTTL is the default time an agent is kept in the cache
+const defaultAgentCacheTTL = 10 * time.Minute
+
+// Agent is a mesos agent running marathon tasks
+type Agent struct {
+	ID       string `json:"id"`
+	Hostname string `json:"hostname"`
+	// Attributes are the agent attributes (e.g. zone and rack), the
+	// scalar and range attributes being formatted as strings.
+	Attributes map[string]string `json:"-"`
+}
+
+// UnmarshalJSON decodes an agent from the mesos master, the attributes
+// values being either strings or numbers
+func (a *Agent) UnmarshalJSON(b []byte) error {
+	var agent struct {
+		ID         string                 `json:"id"`
+		Hostname   string                 `json:"hostname"`
+		Attributes map[string]interface{} `json:"attributes"`
+	}
+
+	if err := json.Unmarshal(b, &agent); err != nil {
+		return err
+	}
+
+	a.ID, a.Hostname = agent.ID, agent.Hostname
+	a.Attributes = make(map[string]string, len(agent.Attributes))
+
+	for k, v := range agent.Attributes {
+		a.Attributes[k] = fmt.Sprint(v)
+	}
+
+	return nil
+}
+
+// Agent returns a mesos agent given its id (the tasks slaveId). The
+// agents are cached, their attributes seldom changing.
+func (c *Client) Agent(id string) (*Agent, error) {
+	return c.AgentContext(context.Background(), id)
+}
+
+// AgentContext returns a mesos agent given its id (the tasks slaveId).
+// The agents are cached, their attributes seldom changing.
+func (c *Client) AgentContext(ctx context.Context, id string) (*Agent, error) {
+	if agent, age := c.agents.get(id); agent != nil && age < c.agents.ttl {
+		return agent, nil
+	}
+
+	var result struct {
+		Slaves []*Agent `json:"slaves"`
+	}
+
+	path := "/slaves?slave_id=" + url.QueryEscape(id)
+
+	if err := c.mesosCall(ctx, path, &result); err != nil {
+		return nil, err
+	}
+
+	for _, agent := range result.Slaves {
+		if agent.ID == id {
+			c.agents.set(agent)
+			return agent, nil
+		}
+	}
+
+	return nil, &Error{
+		StatusCode: http.StatusNotFound,
+		Method:     "GET",
+		Path:       "/slaves",
+		Message:    "agent " + id + " does not exist",
+	}
+}
+
+// Agents returns the agents of the mesos master, which are cached
+func (c *Client) Agents() ([]*Agent, error) {
+	return c.AgentsContext(context.Background())
+}
+
+// AgentsContext returns the agents of the mesos master, which are cached
+func (c *Client) AgentsContext(ctx context.Context) ([]*Agent, error) {
+	var result struct {
+		Slaves []*Agent `json:"slaves"`
+	}
+
+	if err := c.mesosCall(ctx, "/slaves", &result); err != nil {
+		return nil, err
+	}
+
+	for _, agent := range result.Slaves {
+		c.agents.set(agent)
+	}
+
+	return result.Slaves, nil
+}
+
+// CachedAgent returns an agent from the cache without requesting the
+// mesos master or nil if it isn't cached. The agents are kept once
+// expired, stale being true from half their ttl so that the cache is
+// refreshed (see Agents) before they expire.
+func (c *Client) CachedAgent(id string) (agent *Agent, stale bool) {
+	agent, age := c.agents.get(id)
+	return agent, age >= c.agents.ttl/2
+}
+
+// mesosCall sends a GET request to the mesos master with the marathon
+// credentials
+func (c *Client) mesosCall(ctx context.Context, path string, result interface{}) error {
+	if c.config.MesosURI == "" {
+		return errors.New("no mesos master configured")
+	}
+
+	if c.config.RequestTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, c.config.RequestTimeout)
+		defer cancel()
+	}
+
+	resp, err := c.send(ctx, strings.TrimSuffix(c.config.MesosURI, "/"), "GET", path, nil, nil)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 400 {
+		return parseError(resp)
+	}
+
+	b, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+
+	return json.Unmarshal(b, result)
+}
+
+// agentCache keeps the agents retrieved from the mesos master, which
+// are retrieved again once their ttl expires
+type agentCache struct {
+	mu      sync.Mutex
+	ttl     time.Duration
+	entries map[string]*agentEntry
+}
+
+type agentEntry struct {
+	agent *Agent
+	set   time.Time
+}
+
+func newAgentCache(ttl time.Duration) *agentCache {
+	if ttl <= 0 {
+		ttl = defaultAgentCacheTTL
+	}
+
+	return &agentCache{
+		ttl:     ttl,
+		entries: make(map[string]*agentEntry),
+	}
+}
+
+// get returns a cached agent and the time since it was cached, or nil
+// if it is unknown
+func (a *agentCache) get(id string) (*Agent, time.Duration) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	e, ok := a.entries[id]
+	if !ok {
+		return nil, 0
+	}
+
+	return e.agent, time.Since(e.set)
+}
+
+func (a *agentCache) set(agent *Agent) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	a.entries[agent.ID] = &agentEntry{
+		agent: agent,
+		set:   time.Now(),
+	}
+}
@@ -0,0 +1,134 @@
+package marathon
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAgentWithoutError(t *testing.T) {
+	assert := assert.New(t)
+
+	requests := make(chan *apiRequest, 2)
+
+	ts := newAPIServer(http.StatusOK, map[string]interface{}{
+		"slaves": []map[string]interface{}{
+			{
+				"id":         "agent-1",
+				"hostname":   "10.0.0.1",
+				"attributes": map[string]interface{}{"zone": "eu-west-1a", "rack": "r1", "cores": 8},
+			},
+		},
+	}, requests)
+	defer ts.Close()
+
+	client := NewClient(&Config{URI: "http://marathon", MesosURI: ts.URL})
+
+	agent, err := client.Agent("agent-1")
+	assert.NoError(err, "an unexpected error occured in agent")
+
+	rq := <-requests
+	assert.Equal("/slaves", rq.path, "the paths should be equals")
+	assert.Equal("slave_id=agent-1", rq.query, "the queries should be equals")
+
+	assert.Equal("10.0.0.1", agent.Hostname, "the hostnames should be equals")
+	assert.Equal(map[string]string{"zone": "eu-west-1a", "rack": "r1", "cores": "8"}, agent.Attributes, "the attributes should be equals")
+
+	cached, err := client.Agent("agent-1")
+	assert.NoError(err, "an unexpected error occured in agent")
+
+	assert.Equal(agent, cached, "the agents should be equals")
+	assert.Equal(0, len(requests), "the agent should be cached")
+}
+
+func TestAgentWithErrorOnNotFound(t *testing.T) {
+	assert := assert.New(t)
+
+	requests := make(chan *apiRequest, 2)
+
+	ts := newAPIServer(http.StatusOK, map[string]interface{}{"slaves": []interface{}{}}, requests)
+	defer ts.Close()
+
+	client := NewClient(&Config{URI: "http://marathon", MesosURI: ts.URL})
+
+	_, err := client.Agent("agent-1")
+	assert.True(IsNotFound(err), "a not found error was expected in agent")
+
+	_, err = client.Agent("agent-1")
+	assert.True(IsNotFound(err), "a not found error was expected in agent")
+
+	assert.Equal(2, len(requests), "the missing agent shouldn't be cached")
+}
+
+func TestAgentWithErrorWithoutMesos(t *testing.T) {
+	assert := assert.New(t)
+
+	client := NewClient(&Config{URI: "http://marathon"})
+
+	_, err := client.Agent("agent-1")
+	assert.Error(err, "an error was expected without mesos master")
+}
+
+func TestAgentsWithoutError(t *testing.T) {
+	assert := assert.New(t)
+
+	requests := make(chan *apiRequest, 1)
+
+	ts := newAPIServer(http.StatusOK, map[string]interface{}{
+		"slaves": []map[string]interface{}{
+			{"id": "agent-1", "attributes": map[string]interface{}{"zone": "a"}},
+			{"id": "agent-2", "attributes": map[string]interface{}{"zone": "b"}},
+		},
+	}, requests)
+	defer ts.Close()
+
+	client := NewClient(&Config{URI: "http://marathon", MesosURI: ts.URL})
+
+	agent, _ := client.CachedAgent("agent-2")
+	assert.Nil(agent, "the agent shouldn't be cached")
+
+	agents, err := client.Agents()
+	assert.NoError(err, "an unexpected error occured in agents")
+
+	rq := <-requests
+	assert.Equal("/slaves", rq.path, "the paths should be equals")
+	assert.Equal("", rq.query, "the queries should be equals")
+
+	assert.Equal(2, len(agents), "the number of agents should be 2")
+	agent, stale := client.CachedAgent("agent-2")
+	assert.Equal("b", agent.Attributes["zone"], "the zones should be equals")
+	assert.False(stale, "the agent shouldn't be stale")
+}
+
+func TestCachedAgentOnExpiry(t *testing.T) {
+	assert := assert.New(t)
+
+	requests := make(chan *apiRequest, 2)
+
+	ts := newAPIServer(http.StatusOK, map[string]interface{}{
+		"slaves": []map[string]interface{}{
+			{"id": "agent-1", "attributes": map[string]interface{}{"zone": "a"}},
+		},
+	}, requests)
+	defer ts.Close()
+
+	client := NewClient(&Config{URI: "http://marathon", MesosURI: ts.URL, AgentCacheTTL: 100 * time.Millisecond})
+
+	_, err := client.Agents()
+	assert.NoError(err, "an unexpected error occured in agents")
+
+	time.Sleep(150 * time.Millisecond)
+
+	// The expired agent is still served,
+	// marked stale to be refreshed.
+	agent, stale := client.CachedAgent("agent-1")
+	assert.Equal("a", agent.Attributes["zone"], "the zones should be equals")
+	assert.True(stale, "the agent should be stale")
+
+	_, err = client.Agent("agent-1")
+	assert.NoError(err, "an unexpected error occured in agent")
+
+	assert.Equal(2, len(requests), "the expired agent should be requested again")
+}
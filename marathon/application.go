@@ -1,29 +1,297 @@
 package marathon
 
-import "strconv"
+import (
+	"encoding/json"
+	"net"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Embed parameters of the application requests
+const (
+	EmbedAppTasks           = "app.tasks"
+	EmbedAppCounts          = "app.counts"
+	EmbedAppDeployments     = "app.deployments"
+	EmbedAppReadiness       = "app.readiness"
+	EmbedAppLastTaskFailure = "app.lastTaskFailure"
+	EmbedAppTaskStats       = "app.taskStats"
+)
 
 // Application represents the object for an application in marathon
 type Application struct {
-	ID        string             `json:"id,omitempty"`
-	Container *Container         `json:"container,omitempty"`
-	Labels    *map[string]string `json:"labels,omitempty"`
+	ID              string             `json:"id,omitempty"`
+	Cmd             string             `json:"cmd,omitempty"`
+	Args            []string           `json:"args,omitempty"`
+	User            string             `json:"user,omitempty"`
+	Instances       *int               `json:"instances,omitempty"`
+	CPUs            float64            `json:"cpus,omitempty"`
+	Mem             float64            `json:"mem,omitempty"`
+	Disk            float64            `json:"disk,omitempty"`
+	Env             map[string]string  `json:"env,omitempty"`
+	Constraints     [][]string         `json:"constraints,omitempty"`
+	Container       *Container         `json:"container,omitempty"`
+	Networks        []Network          `json:"networks,omitempty"`
+	IPAddress       *IPAddressPerTask  `json:"ipAddress,omitempty"`
+	PortDefinitions *[]PortDefinition  `json:"portDefinitions,omitempty"`
+	Labels          *map[string]string `json:"labels,omitempty"`
+	HealthChecks    []HealthCheck      `json:"healthChecks,omitempty"`
+	Version         string             `json:"version,omitempty"`
+	VersionInfo     *VersionInfo       `json:"versionInfo,omitempty"`
+
+	// Read only attributes, the tasks are
+	// embedded with EmbedAppTasks only.
+	Deployments    []*Deployment `json:"deployments,omitempty"`
+	Tasks          []*Task       `json:"tasks,omitempty"`
+	TasksRunning   int           `json:"tasksRunning,omitempty"`
+	TasksStaged    int           `json:"tasksStaged,omitempty"`
+	TasksHealthy   int           `json:"tasksHealthy,omitempty"`
+	TasksUnhealthy int           `json:"tasksUnhealthy,omitempty"`
+
+	// raw is the definition decoded from marathon, the
+	// attributes which aren't modelled being sent back.
+	raw json.RawMessage
+}
+
+// readOnlyAttributes are the application attributes returned by marathon
+// which aren't part of the definition
+var readOnlyAttributes = []string{
+	"version", "versionInfo", "deployments", "tasks", "tasksRunning",
+	"tasksStaged", "tasksHealthy", "tasksUnhealthy", "lastTaskFailure",
+	"taskStats", "readinessCheckResults",
+}
+
+// UnmarshalJSON decodes an application, keeping its definition so that
+// the attributes the client doesn't model survive an update
+func (a *Application) UnmarshalJSON(b []byte) error {
+	type application Application
+
+	if err := json.Unmarshal(b, (*application)(a)); err != nil {
+		return err
+	}
+
+	a.raw = append(json.RawMessage(nil), b...)
+
+	return nil
+}
+
+// MarshalJSON encodes an application along with the attributes of the
+// definition it was decoded from which the client doesn't model
+func (a Application) MarshalJSON() ([]byte, error) {
+	type application Application
+
+	b, err := json.Marshal(application(a))
+	if err != nil || len(a.raw) == 0 {
+		return b, err
+	}
+
+	return preserve(b, a.raw, reflect.TypeOf(a))
+}
+
+// definition returns the application without its read only attributes,
+// which marathon rejects in an update
+func (a *Application) definition() *Application {
+	d := *a
+	d.Version, d.VersionInfo = "", nil
+	d.Deployments, d.Tasks = nil, nil
+	d.TasksRunning, d.TasksStaged, d.TasksHealthy, d.TasksUnhealthy = 0, 0, 0, 0
+
+	if len(a.raw) > 0 {
+		var attrs map[string]json.RawMessage
+		if err := json.Unmarshal(a.raw, &attrs); err == nil {
+			for _, k := range readOnlyAttributes {
+				delete(attrs, k)
+			}
+			d.raw, _ = json.Marshal(attrs)
+		}
+	}
+
+	return &d
+}
+
+// preserve adds to the json object b the attributes of the raw object
+// which aren't fields of the struct type t. The struct fields are merged
+// the same way, the other fields being kept as is.
+func preserve(b, raw []byte, t reflect.Type) ([]byte, error) {
+	var out, in map[string]json.RawMessage
+
+	if err := json.Unmarshal(b, &out); err != nil || out == nil {
+		return b, nil
+	}
+
+	if err := json.Unmarshal(raw, &in); err != nil {
+		return b, nil
+	}
+
+	fields := jsonFields(t)
+
+	for k, v := range in {
+		ft, known := fields[k]
+		if !known {
+			if _, ok := out[k]; !ok {
+				out[k] = v
+			}
+			continue
+		}
+
+		// A field cleared in the
+		// model isn't restored.
+		cur, ok := out[k]
+		if !ok || ft.Kind() != reflect.Struct {
+			continue
+		}
+
+		merged, err := preserve(cur, v, ft)
+		if err != nil {
+			return nil, err
+		}
+		out[k] = merged
+	}
+
+	return json.Marshal(out)
+}
+
+// jsonFields returns the types of the fields of a struct type given
+// their json names
+func jsonFields(t reflect.Type) map[string]reflect.Type {
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	fields := make(map[string]reflect.Type, t.NumField())
+
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if f.PkgPath != "" {
+			continue
+		}
+
+		name := strings.Split(f.Tag.Get("json"), ",")[0]
+		if name == "-" {
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+
+		ft := f.Type
+		if ft.Kind() == reflect.Ptr {
+			ft = ft.Elem()
+		}
+		fields[name] = ft
+	}
+
+	return fields
+}
+
+// Network modes of a marathon application
+const (
+	NetworkHost      = "host"
+	NetworkBridge    = "container/bridge"
+	NetworkContainer = "container"
+)
+
+// Network is a network joined by an application (marathon 1.5+)
+type Network struct {
+	Name string `json:"name,omitempty"`
+	Mode string `json:"mode"`
+}
+
+// IPAddressPerTask is the legacy ip-per-task definition of an application
+type IPAddressPerTask struct {
+	NetworkName string             `json:"networkName,omitempty"`
+	Labels      *map[string]string `json:"labels,omitempty"`
+}
+
+// NetworkMode returns the network mode of the application tasks. The
+// legacy docker networks (BRIDGE, USER and HOST) and ip-per-task
+// definitions are mapped to their marathon 1.5+ equivalent.
+func (a *Application) NetworkMode() string {
+	if len(a.Networks) > 0 {
+		return a.Networks[0].Mode
+	}
+
+	if a.Container != nil && a.Container.Docker != nil {
+		switch a.Container.Docker.Network {
+		case "BRIDGE":
+			return NetworkBridge
+		case "USER":
+			return NetworkContainer
+		}
+	}
+
+	if a.IPAddress != nil {
+		return NetworkContainer
+	}
+
+	return NetworkHost
+}
+
+// PortMappings returns the container port mappings, defined in the
+// container (marathon 1.5+) or in the docker container (legacy)
+func (a *Application) PortMappings() []PortMapping {
+	if a.Container == nil {
+		return nil
+	}
+
+	if a.Container.PortMappings != nil {
+		return *a.Container.PortMappings
+	}
+
+	if a.Container.Docker != nil && a.Container.Docker.PortMappings != nil {
+		return *a.Container.Docker.PortMappings
+	}
+
+	return nil
+}
+
+// VersionInfo describes the last changes of an application definition
+type VersionInfo struct {
+	LastScalingAt      string `json:"lastScalingAt,omitempty"`
+	LastConfigChangeAt string `json:"lastConfigChangeAt,omitempty"`
+}
+
+// HealthCheck is the definition of a marathon application health check
+type HealthCheck struct {
+	Protocol               string   `json:"protocol,omitempty"`
+	Path                   string   `json:"path,omitempty"`
+	PortIndex              *int     `json:"portIndex,omitempty"`
+	Port                   *int     `json:"port,omitempty"`
+	Command                *Command `json:"command,omitempty"`
+	GracePeriodSeconds     int      `json:"gracePeriodSeconds,omitempty"`
+	IntervalSeconds        int      `json:"intervalSeconds,omitempty"`
+	TimeoutSeconds         int      `json:"timeoutSeconds,omitempty"`
+	MaxConsecutiveFailures int      `json:"maxConsecutiveFailures,omitempty"`
+}
+
+// Command is a command executed by marathon (e.g. COMMAND health checks)
+type Command struct {
+	Value string `json:"value"`
 }
 
 // Container is the definition for a container type in marathon
 type Container struct {
-	Type   string  `json:"type,omitempty"`
-	Docker *Docker `json:"docker,omitempty"`
+	Type         string         `json:"type,omitempty"`
+	Docker       *Docker        `json:"docker,omitempty"`
+	PortMappings *[]PortMapping `json:"portMappings,omitempty"`
 }
 
 // Docker is the docker definition from a marathon application
 type Docker struct {
-	PortMappings *[]PortMapping `json:"portMappings,omitempty"`
+	Image          string         `json:"image,omitempty"`
+	Network        string         `json:"network,omitempty"`
+	Privileged     bool           `json:"privileged,omitempty"`
+	ForcePullImage bool           `json:"forcePullImage,omitempty"`
+	PortMappings   *[]PortMapping `json:"portMappings,omitempty"`
 }
 
-// PortMapping is the portmapping structure between container and mesos
+// PortMapping is the portmapping structure between container and mesos.
+// The host port is optional with container networking and 0 for a port
+// allocated by mesos.
 type PortMapping struct {
 	ContainerPort int                `json:"containerPort,omitempty"`
-	HostPort      int                `json:"hostPort"`
+	HostPort      *int               `json:"hostPort,omitempty"`
 	Labels        *map[string]string `json:"labels,omitempty"`
 	Name          string             `json:"name,omitempty"`
 	ServicePort   int                `json:"servicePort,omitempty"`
@@ -40,12 +308,62 @@ type PortDefinition struct {
 	Labels   *map[string]string `json:"labels,omitempty"`
 }
 
+// Task states reported by mesos
+const (
+	TaskStaging  = "TASK_STAGING"
+	TaskStarting = "TASK_STARTING"
+	TaskRunning  = "TASK_RUNNING"
+	TaskKilling  = "TASK_KILLING"
+	TaskFinished = "TASK_FINISHED"
+	TaskFailed   = "TASK_FAILED"
+	TaskKilled   = "TASK_KILLED"
+	TaskLost     = "TASK_LOST"
+)
+
 // Task represents the definition for a marathon task
 type Task struct {
-	ID    string `json:"id"`
-	AppID string `json:"appId"`
-	Host  string `json:"host"`
-	Ports []int  `json:"ports"`
+	ID                 string              `json:"id"`
+	AppID              string              `json:"appId"`
+	Host               string              `json:"host"`
+	Ports              []int               `json:"ports"`
+	State              string              `json:"state,omitempty"`
+	StartedAt          *time.Time          `json:"startedAt,omitempty"`
+	StagedAt           *time.Time          `json:"stagedAt,omitempty"`
+	Version            string              `json:"version,omitempty"`
+	SlaveID            string              `json:"slaveId,omitempty"`
+	IPAddresses        []IPAddress         `json:"ipAddresses,omitempty"`
+	HealthCheckResults []HealthCheckResult `json:"healthCheckResults,omitempty"`
+}
+
+// Alive returns true if the task is running and the results of the
+// given number of health checks are all alive
+func (t *Task) Alive(healthChecks int) bool {
+	if t.State != "" && t.State != TaskRunning {
+		return false
+	}
+
+	if len(t.HealthCheckResults) < healthChecks {
+		return false
+	}
+
+	for _, result := range t.HealthCheckResults {
+		if !result.Alive {
+			return false
+		}
+	}
+
+	return true
+}
+
+// HealthCheckResult is the result of a marathon health check for a task
+type HealthCheckResult struct {
+	Alive               bool       `json:"alive"`
+	ConsecutiveFailures int        `json:"consecutiveFailures"`
+	FirstSuccess        *time.Time `json:"firstSuccess,omitempty"`
+	LastFailure         *time.Time `json:"lastFailure,omitempty"`
+	LastSuccess         *time.Time `json:"lastSuccess,omitempty"`
+	LastFailureCause    string     `json:"lastFailureCause,omitempty"`
+	InstanceID          string     `json:"instanceId,omitempty"`
 }
 
 // Addr returns the task full address given a port index (format: 192.168.0.1:8080)
@@ -53,6 +371,16 @@ func (t *Task) Addr(portIndex int64) string {
 	return t.Host + ":" + strconv.FormatInt(int64(t.Ports[portIndex]), 10)
 }
 
+// IPAddr returns the task address on its own ip (ip-per-task) given a
+// container port or an empty string if the task has no ip yet
+func (t *Task) IPAddr(containerPort int) string {
+	if len(t.IPAddresses) == 0 {
+		return ""
+	}
+
+	return net.JoinHostPort(t.IPAddresses[0].IPAddress, strconv.Itoa(containerPort))
+}
+
 // IPAddress represents a task's IP address and protocol.
 type IPAddress struct {
 	IPAddress string `json:"ipAddress"`
@@ -0,0 +1,192 @@
+package marathon
+
+import (
+	"context"
+	"errors"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// Application returns an application given its id. The embed parameters
+// (e.g. EmbedAppTasks) add the related objects to the application.
+func (c *Client) Application(id string, embed ...string) (*Application, error) {
+	return c.ApplicationContext(context.Background(), id, embed...)
+}
+
+// ApplicationContext returns an application given its id. The embed
+// parameters (e.g. EmbedAppTasks) add the related objects to the
+// application.
+func (c *Client) ApplicationContext(ctx context.Context, id string, embed ...string) (*Application, error) {
+	var result struct {
+		App *Application `json:"app"`
+	}
+
+	params := url.Values{}
+	for _, e := range embed {
+		params.Add("embed", e)
+	}
+
+	if err := c.apiCall(ctx, "GET", withParams(appPath(id), params), nil, &result); err != nil {
+		return nil, err
+	}
+
+	return result.App, nil
+}
+
+// CreateApplication creates an application and returns the deployment
+// starting its tasks
+func (c *Client) CreateApplication(app *Application) (*DeploymentID, error) {
+	return c.CreateApplicationContext(context.Background(), app)
+}
+
+// CreateApplicationContext creates an application and returns the
+// deployment starting its tasks
+func (c *Client) CreateApplicationContext(ctx context.Context, app *Application) (*DeploymentID, error) {
+	body, err := encode(app.definition())
+	if err != nil {
+		return nil, err
+	}
+
+	created := &Application{}
+
+	if err := c.apiCall(ctx, "POST", "/v2/apps", body, created); err != nil {
+		return nil, err
+	}
+
+	if len(created.Deployments) == 0 {
+		return nil, errors.New("no deployment in marathon response")
+	}
+
+	return &DeploymentID{
+		DeploymentID: created.Deployments[0].ID,
+		Version:      created.Version,
+	}, nil
+}
+
+// UpdateApplication replaces an application definition. The force flag
+// overrides the deployment in progress which locks the application. The
+// attributes of a definition read from marathon which the client doesn't
+// model are sent back as is, the read only ones (e.g. the version and the
+// tasks) being left out.
+func (c *Client) UpdateApplication(app *Application, force bool) (*DeploymentID, error) {
+	return c.UpdateApplicationContext(context.Background(), app, force)
+}
+
+// UpdateApplicationContext replaces an application definition. The
+// force flag overrides the deployment in progress which locks the
+// application. The attributes of a definition read from marathon which
+// the client doesn't model are sent back as is, the read only ones being
+// left out.
+func (c *Client) UpdateApplicationContext(ctx context.Context, app *Application, force bool) (*DeploymentID, error) {
+	// A PUT is a partial update unless
+	// told otherwise by the parameter.
+	params := forceParams(force)
+	params.Set("partialUpdate", "false")
+
+	return c.change(ctx, "PUT", withParams(appPath(app.ID), params), app.definition())
+}
+
+// PatchApplication updates the attributes set in the given application
+// definition only. The force flag overrides the deployment in progress
+// which locks the application.
+func (c *Client) PatchApplication(app *Application, force bool) (*DeploymentID, error) {
+	return c.PatchApplicationContext(context.Background(), app, force)
+}
+
+// PatchApplicationContext updates the attributes set in the given
+// application definition only. The force flag overrides the deployment
+// in progress which locks the application.
+func (c *Client) PatchApplicationContext(ctx context.Context, app *Application, force bool) (*DeploymentID, error) {
+	return c.change(ctx, "PATCH", withParams(appPath(app.ID), forceParams(force)), app.definition())
+}
+
+// ScaleApplication changes the number of instances of an application
+func (c *Client) ScaleApplication(id string, instances int, force bool) (*DeploymentID, error) {
+	return c.ScaleApplicationContext(context.Background(), id, instances, force)
+}
+
+// ScaleApplicationContext changes the number of instances of an
+// application
+func (c *Client) ScaleApplicationContext(ctx context.Context, id string, instances int, force bool) (*DeploymentID, error) {
+	return c.change(ctx, "PUT", withParams(appPath(id), forceParams(force)), &Application{Instances: &instances})
+}
+
+// DeleteApplication destroys an application and its tasks
+func (c *Client) DeleteApplication(id string, force bool) (*DeploymentID, error) {
+	return c.DeleteApplicationContext(context.Background(), id, force)
+}
+
+// DeleteApplicationContext destroys an application and its tasks
+func (c *Client) DeleteApplicationContext(ctx context.Context, id string, force bool) (*DeploymentID, error) {
+	return c.change(ctx, "DELETE", withParams(appPath(id), forceParams(force)), nil)
+}
+
+// RestartApplication replaces the tasks of an application
+func (c *Client) RestartApplication(id string, force bool) (*DeploymentID, error) {
+	return c.RestartApplicationContext(context.Background(), id, force)
+}
+
+// RestartApplicationContext replaces the tasks of an application
+func (c *Client) RestartApplicationContext(ctx context.Context, id string, force bool) (*DeploymentID, error) {
+	return c.change(ctx, "POST", withParams(appPath(id)+"/restart", forceParams(force)), nil)
+}
+
+// ApplicationVersions returns the versions of an application definition
+func (c *Client) ApplicationVersions(id string) ([]string, error) {
+	return c.ApplicationVersionsContext(context.Background(), id)
+}
+
+// ApplicationVersionsContext returns the versions of an application
+// definition
+func (c *Client) ApplicationVersionsContext(ctx context.Context, id string) ([]string, error) {
+	var result struct {
+		Versions []string `json:"versions"`
+	}
+
+	if err := c.apiCall(ctx, "GET", appPath(id)+"/versions", nil, &result); err != nil {
+		return nil, err
+	}
+
+	return result.Versions, nil
+}
+
+// ApplicationVersion returns an application definition at a specific
+// version
+func (c *Client) ApplicationVersion(id, version string) (*Application, error) {
+	return c.ApplicationVersionContext(context.Background(), id, version)
+}
+
+// ApplicationVersionContext returns an application definition at a
+// specific version
+func (c *Client) ApplicationVersionContext(ctx context.Context, id, version string) (*Application, error) {
+	app := &Application{}
+
+	if err := c.apiCall(ctx, "GET", appPath(id)+"/versions/"+url.PathEscape(version), nil, app); err != nil {
+		return nil, err
+	}
+
+	return app, nil
+}
+
+// appPath returns the api path of an application given its id
+func appPath(id string) string {
+	return "/v2/apps/" + strings.TrimPrefix(id, "/")
+}
+
+func forceParams(force bool) url.Values {
+	params := url.Values{}
+	if force {
+		params.Set("force", strconv.FormatBool(force))
+	}
+
+	return params
+}
+
+func withParams(path string, params url.Values) string {
+	if len(params) == 0 {
+		return path
+	}
+
+	return path + "?" + params.Encode()
+}
@@ -0,0 +1,286 @@
+package marathon
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type apiRequest struct {
+	method string
+	path   string
+	query  string
+	body   []byte
+}
+
+func newAPIServer(status int, response interface{}, requests chan *apiRequest) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, rq *http.Request) {
+		if rq.URL.Path == "/v2/leader" {
+			rw.WriteHeader(http.StatusNotFound)
+			return
+		}
+
+		body, _ := ioutil.ReadAll(rq.Body)
+
+		requests <- &apiRequest{
+			method: rq.Method,
+			path:   rq.URL.Path,
+			query:  rq.URL.RawQuery,
+			body:   body,
+		}
+
+		rw.Header().Set("Content-Type", "application/json")
+		rw.WriteHeader(status)
+		_ = json.NewEncoder(rw).Encode(response)
+	}))
+}
+
+func TestApplicationWithoutError(t *testing.T) {
+	assert := assert.New(t)
+
+	requests := make(chan *apiRequest, 1)
+
+	ts := newAPIServer(http.StatusOK, map[string]interface{}{
+		"app": map[string]interface{}{
+			"id":           "/test",
+			"tasksRunning": 1,
+			"tasks": []map[string]interface{}{
+				{"id": "test.1", "host": "127.0.0.1", "ports": []int{8080}},
+			},
+		},
+	}, requests)
+	defer ts.Close()
+
+	client := NewClient(&Config{URI: ts.URL})
+
+	app, err := client.Application("/test", EmbedAppTasks, EmbedAppCounts)
+	assert.NoError(err, "an unexpected error occured in application")
+
+	rq := <-requests
+	assert.Equal("GET", rq.method, "the methods should be equals")
+	assert.Equal("/v2/apps/test", rq.path, "the paths should be equals")
+	assert.Equal("embed=app.tasks&embed=app.counts", rq.query, "the queries should be equals")
+
+	assert.Equal("/test", app.ID, "the application ids should be equals")
+	assert.Equal(1, app.TasksRunning, "the number of running tasks should be 1")
+	assert.Equal(1, len(app.Tasks), "the number of tasks should be 1")
+	assert.Equal("127.0.0.1:8080", app.Tasks[0].Addr(0), "the addresses should be equals")
+}
+
+func TestApplicationWithErrorOnNotFound(t *testing.T) {
+	assert := assert.New(t)
+
+	requests := make(chan *apiRequest, 1)
+
+	ts := newAPIServer(http.StatusNotFound, map[string]interface{}{
+		"message": "App '/test' does not exist",
+	}, requests)
+	defer ts.Close()
+
+	client := NewClient(&Config{URI: ts.URL})
+
+	app, err := client.Application("/test")
+	assert.Error(err, "an error was expected in application")
+
+	assert.True(IsNotFound(err), "the error should be a not found error")
+	assert.Nil(app, "the application should be nil")
+}
+
+func TestCreateApplicationWithoutError(t *testing.T) {
+	assert := assert.New(t)
+
+	requests := make(chan *apiRequest, 1)
+
+	ts := newAPIServer(http.StatusCreated, map[string]interface{}{
+		"id":          "/test",
+		"version":     "2019-01-01T00:00:00.000Z",
+		"deployments": []map[string]interface{}{{"id": "deployment-1"}},
+	}, requests)
+	defer ts.Close()
+
+	client := NewClient(&Config{URI: ts.URL})
+
+	instances := 2
+
+	deployment, err := client.CreateApplication(&Application{
+		ID:        "/test",
+		Cmd:       "sleep 100",
+		Instances: &instances,
+	})
+	assert.NoError(err, "an unexpected error occured in application creation")
+
+	rq := <-requests
+	assert.Equal("POST", rq.method, "the methods should be equals")
+	assert.Equal("/v2/apps", rq.path, "the paths should be equals")
+	assert.JSONEq(`{"id":"/test","cmd":"sleep 100","instances":2}`, string(rq.body), "the bodies should be equals")
+
+	assert.Equal("deployment-1", deployment.DeploymentID, "the deployment ids should be equals")
+	assert.Equal("2019-01-01T00:00:00.000Z", deployment.Version, "the versions should be equals")
+}
+
+func TestApplicationChangesWithoutError(t *testing.T) {
+	assert := assert.New(t)
+
+	requests := make(chan *apiRequest, 1)
+
+	ts := newAPIServer(http.StatusOK, map[string]interface{}{
+		"deploymentId": "deployment-1",
+		"version":      "2019-01-01T00:00:00.000Z",
+	}, requests)
+	defer ts.Close()
+
+	client := NewClient(&Config{URI: ts.URL})
+
+	app := &Application{ID: "/test", Cmd: "sleep 100"}
+
+	tests := []struct {
+		name   string
+		call   func() (*DeploymentID, error)
+		method string
+		path   string
+		query  string
+		body   string
+	}{
+		{"update", func() (*DeploymentID, error) { return client.UpdateApplication(app, true) }, "PUT", "/v2/apps/test", "force=true&partialUpdate=false", `{"id":"/test","cmd":"sleep 100"}`},
+		{"update without force", func() (*DeploymentID, error) { return client.UpdateApplication(app, false) }, "PUT", "/v2/apps/test", "partialUpdate=false", `{"id":"/test","cmd":"sleep 100"}`},
+		{"patch", func() (*DeploymentID, error) { return client.PatchApplication(app, false) }, "PATCH", "/v2/apps/test", "", `{"id":"/test","cmd":"sleep 100"}`},
+		{"scale", func() (*DeploymentID, error) { return client.ScaleApplication("/test", 3, false) }, "PUT", "/v2/apps/test", "", `{"instances":3}`},
+		{"restart", func() (*DeploymentID, error) { return client.RestartApplication("/test", true) }, "POST", "/v2/apps/test/restart", "force=true", ""},
+		{"delete", func() (*DeploymentID, error) { return client.DeleteApplication("/test", false) }, "DELETE", "/v2/apps/test", "", ""},
+	}
+
+	for _, test := range tests {
+		deployment, err := test.call()
+		assert.NoError(err, "an unexpected error occured in application "+test.name)
+
+		rq := <-requests
+		assert.Equal(test.method, rq.method, "the methods should be equals")
+		assert.Equal(test.path, rq.path, "the paths should be equals")
+		assert.Equal(test.query, rq.query, "the queries should be equals")
+
+		if test.body != "" {
+			assert.JSONEq(test.body, string(rq.body), "the bodies should be equals")
+		} else {
+			assert.Empty(rq.body, "the body should be empty")
+		}
+
+		assert.Equal("deployment-1", deployment.DeploymentID, "the deployment ids should be equals")
+	}
+}
+
+func TestApplicationVersionsWithoutError(t *testing.T) {
+	assert := assert.New(t)
+
+	requests := make(chan *apiRequest, 1)
+
+	ts := newAPIServer(http.StatusOK, map[string]interface{}{
+		"versions": []string{"2019-01-02T00:00:00.000Z", "2019-01-01T00:00:00.000Z"},
+	}, requests)
+	defer ts.Close()
+
+	client := NewClient(&Config{URI: ts.URL})
+
+	versions, err := client.ApplicationVersions("/test")
+	assert.NoError(err, "an unexpected error occured in application versions")
+
+	rq := <-requests
+	assert.Equal("/v2/apps/test/versions", rq.path, "the paths should be equals")
+
+	assert.Equal([]string{"2019-01-02T00:00:00.000Z", "2019-01-01T00:00:00.000Z"}, versions, "the versions should be equals")
+}
+
+func TestApplicationVersionWithoutError(t *testing.T) {
+	assert := assert.New(t)
+
+	requests := make(chan *apiRequest, 1)
+
+	ts := newAPIServer(http.StatusOK, map[string]interface{}{
+		"id":      "/test",
+		"version": "2019-01-01T00:00:00.000Z",
+	}, requests)
+	defer ts.Close()
+
+	client := NewClient(&Config{URI: ts.URL})
+
+	app, err := client.ApplicationVersion("/test", "2019-01-01T00:00:00.000Z")
+	assert.NoError(err, "an unexpected error occured in application version")
+
+	rq := <-requests
+	assert.Equal("/v2/apps/test/versions/2019-01-01T00:00:00.000Z", rq.path, "the paths should be equals")
+
+	assert.Equal("2019-01-01T00:00:00.000Z", app.Version, "the versions should be equals")
+}
+
+func TestApplicationUpdateRoundTrip(t *testing.T) {
+	assert := assert.New(t)
+
+	definition := `{
+		"id": "/test",
+		"cmd": "sleep 100",
+		"instances": 2,
+		"fetch": [{"uri": "https://example.com/app.tgz", "extract": true}],
+		"upgradeStrategy": {"minimumHealthCapacity": 1, "maximumOverCapacity": 1},
+		"acceptedResourceRoles": ["*"],
+		"secrets": {"secret0": {"source": "db-password"}},
+		"unreachableStrategy": {"inactiveAfterSeconds": 0, "expungeAfterSeconds": 0},
+		"container": {
+			"type": "DOCKER",
+			"docker": {"image": "test:1", "parameters": [{"key": "log-driver", "value": "none"}]},
+			"volumes": [{"containerPath": "/data", "hostPath": "/var/data", "mode": "RW"}]
+		}
+	}`
+
+	requests := make(chan *apiRequest, 1)
+
+	ts := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, rq *http.Request) {
+		rw.Header().Set("Content-Type", "application/json")
+
+		if rq.Method == "GET" {
+			// The definition is returned with
+			// the read only attributes.
+			app := map[string]interface{}{}
+			_ = json.Unmarshal([]byte(definition), &app)
+
+			app["version"] = "2019-01-01T00:00:00.000Z"
+			app["versionInfo"] = map[string]string{"lastConfigChangeAt": "2019-01-01T00:00:00.000Z"}
+			app["tasksRunning"] = 2
+			app["tasksHealthy"] = 2
+			app["deployments"] = []interface{}{}
+			app["tasks"] = []map[string]interface{}{{"id": "test.1", "host": "10.0.0.1"}}
+			app["lastTaskFailure"] = map[string]string{"taskId": "test.0"}
+
+			_ = json.NewEncoder(rw).Encode(map[string]interface{}{"app": app})
+			return
+		}
+
+		body, _ := ioutil.ReadAll(rq.Body)
+		requests <- &apiRequest{method: rq.Method, path: rq.URL.Path, query: rq.URL.RawQuery, body: body}
+
+		_, _ = rw.Write([]byte(`{"deploymentId":"deployment-1","version":"2019-01-02T00:00:00.000Z"}`))
+	}))
+	defer ts.Close()
+
+	client := NewClient(&Config{URI: ts.URL})
+
+	app, err := client.Application("/test", EmbedAppTasks, EmbedAppLastTaskFailure)
+	assert.NoError(err, "an unexpected error occured in application")
+
+	instances := 3
+	app.Instances = &instances
+	app.Container.Docker.Image = "test:2"
+
+	_, err = client.UpdateApplication(app, false)
+	assert.NoError(err, "an unexpected error occured in application update")
+
+	rq := <-requests
+	assert.Equal("PUT", rq.method, "the methods should be equals")
+	assert.Equal("partialUpdate=false", rq.query, "the queries should be equals")
+
+	expected := strings.NewReplacer(`"instances": 2`, `"instances": 3`, `"test:1"`, `"test:2"`).Replace(definition)
+	assert.JSONEq(expected, string(rq.body), "the bodies should be equals")
+}
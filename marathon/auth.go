@@ -0,0 +1,254 @@
+package marathon
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// loginPath is the DC/OS IAM login endpoint
+	loginPath = "/acs/api/v1/auth/login"
+
+	// loginTokenTTL is the validity of the tokens signed to log in
+	loginTokenTTL = 5 * time.Minute
+
+	// defaultTokenTTL is the validity assumed for an authentication token
+	// which doesn't carry its expiration
+	defaultTokenTTL = time.Hour
+
+	// tokenExpiryMargin is the margin before the token expiration from
+	// which the token is refreshed
+	tokenExpiryMargin = time.Minute
+)
+
+// ServiceAccount represents the DC/OS IAM service account credentials
+// used to log in and retrieve authentication tokens
+type ServiceAccount struct {
+	// UID is the service account identifier
+	UID string
+	// PrivateKey is the PEM encoded RSA private key of the service account
+	PrivateKey []byte
+	// LoginURL is the DC/OS IAM login url (default: {URI scheme and host}/acs/api/v1/auth/login)
+	LoginURL string
+}
+
+// tokenSource logs in with a service account and caches the
+// authentication token until it expires or is rejected by marathon
+type tokenSource struct {
+	client  *Client
+	account *ServiceAccount
+
+	mu     sync.Mutex
+	key    *rsa.PrivateKey
+	token  string
+	expiry time.Time
+}
+
+func newTokenSource(c *Client, account *ServiceAccount) *tokenSource {
+	return &tokenSource{
+		client:  c,
+		account: account,
+	}
+}
+
+// Token returns the cached authentication token or logs in if the
+// token is missing or about to expire
+func (s *tokenSource) Token(ctx context.Context) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.token != "" && time.Now().Add(tokenExpiryMargin).Before(s.expiry) {
+		return s.token, nil
+	}
+
+	token, err := s.login(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	s.token = token
+	s.expiry = tokenExpiry(token)
+
+	return token, nil
+}
+
+// invalidate discards the given token if it is still cached
+func (s *tokenSource) invalidate(token string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.token == token {
+		s.token = ""
+	}
+}
+
+func (s *tokenSource) login(ctx context.Context) (string, error) {
+	if s.key == nil {
+		key, err := parsePrivateKey(s.account.PrivateKey)
+		if err != nil {
+			return "", err
+		}
+		s.key = key
+	}
+
+	loginToken, err := signLoginToken(s.account.UID, s.key, time.Now().Add(loginTokenTTL))
+	if err != nil {
+		return "", err
+	}
+
+	b, err := json.Marshal(map[string]string{
+		"uid":   s.account.UID,
+		"token": loginToken,
+	})
+	if err != nil {
+		return "", err
+	}
+
+	loginURL, err := s.loginURL()
+	if err != nil {
+		return "", err
+	}
+
+	req, err := http.NewRequest("POST", loginURL, bytes.NewReader(b))
+	if err != nil {
+		return "", err
+	}
+
+	req.Header.Add("Content-Type", "application/json")
+
+	resp, err := s.client.httpClient().Do(req.WithContext(ctx))
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 400 {
+		return "", parseError(resp)
+	}
+
+	var result struct {
+		Token string `json:"token"`
+	}
+
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return "", err
+	}
+
+	if result.Token == "" {
+		return "", errors.New("empty token in DC/OS login response")
+	}
+
+	return result.Token, nil
+}
+
+func (s *tokenSource) loginURL() (string, error) {
+	if s.account.LoginURL != "" {
+		return s.account.LoginURL, nil
+	}
+
+	u, err := url.Parse(s.client.URI())
+	if err != nil {
+		return "", err
+	}
+
+	return u.Scheme + "://" + u.Host + loginPath, nil
+}
+
+// signLoginToken returns a RS256 json web token asserting the service
+// account identity
+func signLoginToken(uid string, key *rsa.PrivateKey, exp time.Time) (string, error) {
+	header, err := json.Marshal(map[string]string{
+		"alg": "RS256",
+		"typ": "JWT",
+	})
+	if err != nil {
+		return "", err
+	}
+
+	claims, err := json.Marshal(map[string]interface{}{
+		"uid": uid,
+		"exp": exp.Unix(),
+	})
+	if err != nil {
+		return "", err
+	}
+
+	signing := encodeSegment(header) + "." + encodeSegment(claims)
+
+	hash := sha256.Sum256([]byte(signing))
+
+	sig, err := rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, hash[:])
+	if err != nil {
+		return "", err
+	}
+
+	return signing + "." + encodeSegment(sig), nil
+}
+
+// tokenExpiry returns the expiration of an authentication token. The
+// token isn't verified, its claims are only used to schedule the
+// refresh.
+func tokenExpiry(token string) time.Time {
+	fallback := time.Now().Add(defaultTokenTTL)
+
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return fallback
+	}
+
+	b, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return fallback
+	}
+
+	var claims struct {
+		Exp int64 `json:"exp"`
+	}
+
+	if err := json.Unmarshal(b, &claims); err != nil || claims.Exp == 0 {
+		return fallback
+	}
+
+	return time.Unix(claims.Exp, 0)
+}
+
+func parsePrivateKey(b []byte) (*rsa.PrivateKey, error) {
+	block, _ := pem.Decode(b)
+	if block == nil {
+		return nil, errors.New("invalid PEM encoded private key")
+	}
+
+	if key, err := x509.ParsePKCS1PrivateKey(block.Bytes); err == nil {
+		return key, nil
+	}
+
+	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+
+	rsaKey, ok := key.(*rsa.PrivateKey)
+	if !ok {
+		return nil, errors.New("the private key isn't a RSA key")
+	}
+
+	return rsaKey, nil
+}
+
+func encodeSegment(b []byte) string {
+	return base64.RawURLEncoding.EncodeToString(b)
+}
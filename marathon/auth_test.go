@@ -0,0 +1,223 @@
+package marathon
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// dcos is a stand-in for the DC/OS IAM login endpoint and marathon
+type dcos struct {
+	mu      sync.Mutex
+	key     *rsa.PublicKey
+	uid     string
+	logins  int
+	revoked map[string]bool
+}
+
+func (d *dcos) ServeHTTP(rw http.ResponseWriter, rq *http.Request) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	switch rq.URL.Path {
+	case loginPath:
+		var body struct {
+			UID   string `json:"uid"`
+			Token string `json:"token"`
+		}
+
+		if err := json.NewDecoder(rq.Body).Decode(&body); err != nil || body.UID != d.uid || !d.verify(body.Token) {
+			rw.WriteHeader(http.StatusUnauthorized)
+			fmt.Fprint(rw, `{"title":"Unauthorized","description":"invalid login token"}`)
+			return
+		}
+
+		d.logins++
+
+		claims := encodeSegment([]byte(fmt.Sprintf(`{"uid":"%s","exp":%d}`, d.uid, time.Now().Add(time.Hour).Unix())))
+		fmt.Fprintf(rw, `{"token":"header.%s.%d"}`, claims, d.logins)
+	default:
+		token := strings.TrimPrefix(rq.Header.Get("Authorization"), "token=")
+		if token == "" || d.revoked[token] || !strings.HasPrefix(token, "header.") {
+			rw.WriteHeader(http.StatusUnauthorized)
+			fmt.Fprint(rw, `{"message":"unauthorized"}`)
+			return
+		}
+
+		rw.WriteHeader(http.StatusOK)
+	}
+}
+
+func (d *dcos) verify(token string) bool {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return false
+	}
+
+	sig, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return false
+	}
+
+	hash := sha256.Sum256([]byte(parts[0] + "." + parts[1]))
+
+	return rsa.VerifyPKCS1v15(d.key, crypto.SHA256, hash[:], sig) == nil
+}
+
+func (d *dcos) revoke(token string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.revoked[token] = true
+}
+
+func newServiceAccount(t *testing.T) (*rsa.PrivateKey, []byte) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("couldn't generate private key: %v", err)
+	}
+
+	return key, pem.EncodeToMemory(&pem.Block{
+		Type:  "RSA PRIVATE KEY",
+		Bytes: x509.MarshalPKCS1PrivateKey(key),
+	})
+}
+
+func TestServiceAccountLoginWithoutError(t *testing.T) {
+	assert := assert.New(t)
+
+	key, privateKey := newServiceAccount(t)
+
+	d := &dcos{key: &key.PublicKey, uid: "resolver", revoked: make(map[string]bool)}
+
+	ts := httptest.NewServer(d)
+	defer ts.Close()
+
+	client := NewClient(&Config{
+		URI: ts.URL + "/service/marathon",
+		ServiceAccount: &ServiceAccount{
+			UID:        "resolver",
+			PrivateKey: privateKey,
+		},
+	})
+
+	assert.NoError(client.Ping(), "an unexpected error occured in ping")
+	assert.NoError(client.Ping(), "an unexpected error occured in ping")
+
+	assert.Equal(1, d.logins, "the token should be cached")
+}
+
+func TestServiceAccountRefreshOnUnauthorized(t *testing.T) {
+	assert := assert.New(t)
+
+	key, privateKey := newServiceAccount(t)
+
+	d := &dcos{key: &key.PublicKey, uid: "resolver", revoked: make(map[string]bool)}
+
+	ts := httptest.NewServer(d)
+	defer ts.Close()
+
+	client := NewClient(&Config{
+		URI: ts.URL,
+		ServiceAccount: &ServiceAccount{
+			UID:        "resolver",
+			PrivateKey: privateKey,
+		},
+	})
+
+	assert.NoError(client.Ping(), "an unexpected error occured in ping")
+
+	token, err := client.tokens.Token(context.Background())
+	assert.NoError(err, "an unexpected error occured in token retrieval")
+
+	d.revoke(token)
+
+	assert.NoError(client.Ping(), "an unexpected error occured in ping")
+	assert.Equal(2, d.logins, "the token should be refreshed")
+}
+
+func TestServiceAccountRefreshOnExpiry(t *testing.T) {
+	assert := assert.New(t)
+
+	key, privateKey := newServiceAccount(t)
+
+	d := &dcos{key: &key.PublicKey, uid: "resolver", revoked: make(map[string]bool)}
+
+	ts := httptest.NewServer(d)
+	defer ts.Close()
+
+	client := NewClient(&Config{
+		URI: ts.URL,
+		ServiceAccount: &ServiceAccount{
+			UID:        "resolver",
+			PrivateKey: privateKey,
+		},
+	})
+
+	assert.NoError(client.Ping(), "an unexpected error occured in ping")
+
+	client.tokens.expiry = time.Now()
+
+	assert.NoError(client.Ping(), "an unexpected error occured in ping")
+	assert.Equal(2, d.logins, "the token should be refreshed")
+}
+
+func TestServiceAccountLoginWithErrorOnWrongKey(t *testing.T) {
+	assert := assert.New(t)
+
+	key, _ := newServiceAccount(t)
+	_, privateKey := newServiceAccount(t)
+
+	d := &dcos{key: &key.PublicKey, uid: "resolver", revoked: make(map[string]bool)}
+
+	ts := httptest.NewServer(d)
+	defer ts.Close()
+
+	client := NewClient(&Config{
+		URI: ts.URL,
+		ServiceAccount: &ServiceAccount{
+			UID:        "resolver",
+			PrivateKey: privateKey,
+		},
+	})
+
+	assert.Error(client.Ping(), "an error was expected in ping")
+}
+
+func TestStaticTokenWithoutError(t *testing.T) {
+	assert := assert.New(t)
+
+	handler := http.HandlerFunc(func(rw http.ResponseWriter, rq *http.Request) {
+		if rq.Header.Get("Authorization") != "token=static" {
+			rw.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		rw.WriteHeader(http.StatusOK)
+	})
+
+	ts := httptest.NewServer(handler)
+	defer ts.Close()
+
+	client := NewClient(&Config{
+		URI:               ts.URL,
+		DCOSToken:         "static",
+		HTTPBasicAuthUser: "user",
+	})
+
+	assert.NoError(client.Ping(), "an unexpected error occured in ping")
+}
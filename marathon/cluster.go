@@ -0,0 +1,156 @@
+package marathon
+
+import (
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// minDownInterval is the time a member is skipped after its first failure
+	minDownInterval = time.Second
+
+	// maxDownInterval is the maximum time a failing member is skipped
+	maxDownInterval = time.Minute
+
+	// leaderRefreshInterval is the interval between two leader lookups
+	leaderRefreshInterval = 30 * time.Second
+)
+
+// cluster is the set of marathon instances known by the client. The
+// requests are sent to the leader first and rotate over the other
+// members when an instance fails.
+type cluster struct {
+	mu            sync.Mutex
+	members       []*member
+	next          int
+	leader        string
+	leaderChecked time.Time
+}
+
+// member is a marathon instance of the cluster
+type member struct {
+	uri       string
+	host      string
+	failures  int
+	downUntil time.Time
+}
+
+func newCluster(uris []string) *cluster {
+	c := &cluster{}
+
+	for _, uri := range uris {
+		uri = strings.TrimRight(strings.TrimSpace(uri), "/")
+		if uri == "" {
+			continue
+		}
+
+		if !strings.Contains(uri, "://") {
+			uri = "http://" + uri
+		}
+
+		host := uri[strings.Index(uri, "://")+3:]
+		if i := strings.Index(host, "/"); i >= 0 {
+			host = host[:i]
+		}
+
+		c.members = append(c.members, &member{
+			uri:  uri,
+			host: host,
+		})
+	}
+
+	return c
+}
+
+// endpoints returns the members uris in the order they should be tried:
+// the leader, the available members in turn, and the members marked
+// down last.
+func (c *cluster) endpoints() []string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := time.Now()
+
+	var up, down []string
+
+	for i := range c.members {
+		m := c.members[(c.next+i)%len(c.members)]
+
+		switch {
+		case now.Before(m.downUntil):
+			down = append(down, m.uri)
+		case m.host == c.leader:
+			up = append([]string{m.uri}, up...)
+		default:
+			up = append(up, m.uri)
+		}
+	}
+
+	return append(up, down...)
+}
+
+// markDown skips a member for a backoff growing with its failures and
+// rotates the next requests to the following member
+func (c *cluster) markDown(uri string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for i, m := range c.members {
+		if m.uri != uri {
+			continue
+		}
+
+		m.failures++
+
+		backoff := maxDownInterval
+		if m.failures < 8 && minDownInterval<<uint(m.failures-1) < maxDownInterval {
+			backoff = minDownInterval << uint(m.failures-1)
+		}
+
+		m.downUntil = time.Now().Add(backoff)
+		c.next = (i + 1) % len(c.members)
+
+		if m.host == c.leader {
+			c.leader = ""
+		}
+	}
+}
+
+// markUp resets the failures of a member
+func (c *cluster) markUp(uri string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for _, m := range c.members {
+		if m.uri == uri {
+			m.failures = 0
+			m.downUntil = time.Time{}
+		}
+	}
+}
+
+// setLeader records the leader address (format: host:port)
+func (c *cluster) setLeader(leader string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.leader = leader
+	c.leaderChecked = time.Now()
+}
+
+// leaderStale returns true if the leader should be looked up again. The
+// lookup is only relevant with several members.
+func (c *cluster) leaderStale() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if len(c.members) < 2 || time.Since(c.leaderChecked) < leaderRefreshInterval {
+		return false
+	}
+
+	// Prevents concurrent lookups
+	c.leaderChecked = time.Now()
+
+	return true
+}
@@ -0,0 +1,138 @@
+package marathon
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func newMarathon(status int, leader *string, hits *int32) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, rq *http.Request) {
+		if rq.URL.Path == "/v2/leader" {
+			fmt.Fprintf(rw, `{"leader":"%s"}`, *leader)
+			return
+		}
+
+		atomic.AddInt32(hits, 1)
+
+		rw.WriteHeader(status)
+		fmt.Fprint(rw, `{"message":"test"}`)
+	}))
+}
+
+func TestClientFailoverOnConnectionError(t *testing.T) {
+	assert := assert.New(t)
+
+	var leader string
+	var hits int32
+
+	down := httptest.NewServer(http.NotFoundHandler())
+	down.Close()
+
+	up := newMarathon(http.StatusOK, &leader, &hits)
+	defer up.Close()
+
+	client := NewClient(&Config{
+		URI:       down.URL,
+		Endpoints: []string{up.URL},
+	})
+
+	assert.NoError(client.Ping(), "an unexpected error occured in ping")
+	assert.Equal(up.URL, client.URI(), "the failing instance should be marked down")
+	assert.Equal(int32(1), atomic.LoadInt32(&hits), "the request should be sent once to the available instance")
+}
+
+func TestClientFailoverOnServerError(t *testing.T) {
+	assert := assert.New(t)
+
+	var leader string
+	var failing, available int32
+
+	s1 := newMarathon(http.StatusServiceUnavailable, &leader, &failing)
+	defer s1.Close()
+
+	s2 := newMarathon(http.StatusOK, &leader, &available)
+	defer s2.Close()
+
+	client := NewClient(&Config{
+		URI:       s1.URL,
+		Endpoints: []string{s2.URL},
+	})
+
+	assert.NoError(client.Ping(), "an unexpected error occured in ping")
+	assert.NoError(client.Ping(), "an unexpected error occured in ping")
+
+	assert.Equal(int32(1), atomic.LoadInt32(&failing), "the failing instance should be skipped")
+	assert.Equal(int32(2), atomic.LoadInt32(&available), "the requests should be sent to the available instance")
+}
+
+func TestClientWithErrorOnAllInstancesDown(t *testing.T) {
+	assert := assert.New(t)
+
+	var leader string
+	var hits int32
+
+	s1 := newMarathon(http.StatusInternalServerError, &leader, &hits)
+	defer s1.Close()
+
+	s2 := newMarathon(http.StatusInternalServerError, &leader, &hits)
+	defer s2.Close()
+
+	client := NewClient(&Config{
+		URI:       s1.URL,
+		Endpoints: []string{s2.URL},
+	})
+
+	assert.Error(client.Ping(), "an error was expected in ping")
+	assert.Equal(int32(2), atomic.LoadInt32(&hits), "every instance should be tried")
+}
+
+func TestClientLeaderPreference(t *testing.T) {
+	assert := assert.New(t)
+
+	var leader string
+	var h1, h2 int32
+
+	s1 := newMarathon(http.StatusOK, &leader, &h1)
+	defer s1.Close()
+
+	s2 := newMarathon(http.StatusOK, &leader, &h2)
+	defer s2.Close()
+
+	leader = strings.TrimPrefix(s2.URL, "http://")
+
+	client := NewClient(&Config{
+		URI:       s1.URL,
+		Endpoints: []string{s2.URL},
+	})
+
+	res, err := client.Leader()
+	assert.NoError(err, "an unexpected error occured in leader")
+
+	assert.Equal(leader, res, "the leaders should be equals")
+	assert.Equal(s2.URL, client.URI(), "the leader should be preferred")
+
+	assert.NoError(client.Ping(), "an unexpected error occured in ping")
+	assert.Equal(int32(1), atomic.LoadInt32(&h2), "the ping should be sent to the leader")
+}
+
+func TestClusterEndpointsWithoutScheme(t *testing.T) {
+	assert := assert.New(t)
+
+	c := newCluster([]string{"marathon.mesos:8080/", "", "https://marathon-2:8443"})
+
+	assert.Equal([]string{"http://marathon.mesos:8080", "https://marathon-2:8443"}, c.endpoints(), "the endpoints should be equals")
+
+	c.markDown("http://marathon.mesos:8080")
+
+	assert.Equal([]string{"https://marathon-2:8443", "http://marathon.mesos:8080"}, c.endpoints(), "the instance marked down should be last")
+
+	c.markUp("http://marathon.mesos:8080")
+
+	assert.Equal("http://marathon.mesos:8080", c.endpoints()[1], "the instance should be available")
+}
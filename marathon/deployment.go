@@ -0,0 +1,323 @@
+package marathon
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// deploymentPollInterval is the interval between two deployment checks
+// when the event stream is unavailable
+const deploymentPollInterval = 2 * time.Second
+
+// deploymentOutcomeDelay is the time the outcome event of a deployment
+// is waited for once the deployment left the list
+const deploymentOutcomeDelay = time.Second
+
+var (
+	// ErrDeploymentFailed is returned when a deployment waited for fails
+	ErrDeploymentFailed = errors.New("marathon deployment failed")
+	// ErrDeploymentUnknown is returned when a deployment waited for is
+	// over but its outcome isn't known
+	ErrDeploymentUnknown = errors.New("marathon deployment over with an unknown outcome")
+)
+
+// Deployment represents a marathon deployment
+type Deployment struct {
+	ID             string              `json:"id"`
+	Version        string              `json:"version,omitempty"`
+	AffectedApps   []string            `json:"affectedApps,omitempty"`
+	AffectedPods   []string            `json:"affectedPods,omitempty"`
+	Steps          []*DeploymentStep   `json:"steps,omitempty"`
+	CurrentActions []*DeploymentAction `json:"currentActions,omitempty"`
+	CurrentStep    int                 `json:"currentStep,omitempty"`
+	TotalSteps     int                 `json:"totalSteps,omitempty"`
+}
+
+// DeploymentStep is a set of actions executed in parallel by a deployment
+type DeploymentStep struct {
+	Actions []*DeploymentAction `json:"actions"`
+}
+
+// DeploymentAction is an action on an application or a pod
+type DeploymentAction struct {
+	Action string `json:"action"`
+	App    string `json:"app,omitempty"`
+	Pod    string `json:"pod,omitempty"`
+}
+
+// DeploymentPlan is the plan of a deployment sent in the deployment events
+type DeploymentPlan struct {
+	ID      string            `json:"id"`
+	Version string            `json:"version,omitempty"`
+	Steps   []*DeploymentStep `json:"steps,omitempty"`
+}
+
+// DeploymentID identifies the deployment started by a change in marathon
+type DeploymentID struct {
+	DeploymentID string `json:"deploymentId"`
+	Version      string `json:"version"`
+}
+
+// Deployments returns the deployments in progress
+func (c *Client) Deployments() ([]*Deployment, error) {
+	return c.DeploymentsContext(context.Background())
+}
+
+// DeploymentsContext returns the deployments in progress
+func (c *Client) DeploymentsContext(ctx context.Context) ([]*Deployment, error) {
+	deployments := []*Deployment{}
+
+	if err := c.apiCall(ctx, "GET", "/v2/deployments", nil, &deployments); err != nil {
+		return nil, err
+	}
+
+	return deployments, nil
+}
+
+// Deployment returns a deployment in progress given its id. A not found
+// error is returned once the deployment is over.
+func (c *Client) Deployment(id string) (*Deployment, error) {
+	return c.DeploymentContext(context.Background(), id)
+}
+
+// DeploymentContext returns a deployment in progress given its id. A not
+// found error is returned once the deployment is over.
+func (c *Client) DeploymentContext(ctx context.Context, id string) (*Deployment, error) {
+	deployments, err := c.DeploymentsContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, d := range deployments {
+		if d.ID == id {
+			return d, nil
+		}
+	}
+
+	return nil, &Error{
+		StatusCode: http.StatusNotFound,
+		Method:     "GET",
+		Path:       "/v2/deployments",
+		Message:    "deployment " + id + " does not exist",
+	}
+}
+
+// DeleteDeployment cancels a deployment. Marathon rolls the changes back
+// through a new deployment which is returned. The force flag stops the
+// deployment without rollback and no deployment is returned.
+func (c *Client) DeleteDeployment(id string, force bool) (*DeploymentID, error) {
+	return c.DeleteDeploymentContext(context.Background(), id, force)
+}
+
+// DeleteDeploymentContext cancels a deployment. Marathon rolls the
+// changes back through a new deployment which is returned. The force
+// flag stops the deployment without rollback and no deployment is
+// returned.
+func (c *Client) DeleteDeploymentContext(ctx context.Context, id string, force bool) (*DeploymentID, error) {
+	path := withParams("/v2/deployments/"+url.PathEscape(id), forceParams(force))
+
+	if force {
+		return nil, c.apiCall(ctx, "DELETE", path, nil, nil)
+	}
+
+	deployment := &DeploymentID{}
+
+	if err := c.apiCall(ctx, "DELETE", path, nil, deployment); err != nil {
+		return nil, err
+	}
+
+	return deployment, nil
+}
+
+// change sends a change of a marathon object and returns the deployment
+// applying it. The object is the request body if not nil.
+func (c *Client) change(ctx context.Context, method, path string, object interface{}) (*DeploymentID, error) {
+	var body io.Reader
+	if object != nil {
+		var err error
+		if body, err = encode(object); err != nil {
+			return nil, err
+		}
+	}
+
+	deployment := &DeploymentID{}
+
+	if err := c.apiCall(ctx, method, path, body, deployment); err != nil {
+		return nil, err
+	}
+
+	return deployment, nil
+}
+
+// WaitForDeployment blocks until a deployment is over or the context is
+// done. The deployment events are used when the event stream is
+// available, marathon being polled otherwise. ErrDeploymentFailed is
+// returned if the deployment fails. Without an event telling the outcome,
+// the affected applications and pods must run the deployed version, a
+// rollback or a newer deployment having replaced it otherwise.
+// ErrDeploymentUnknown is returned if the deployment was over before
+// being checked. The progress function (optional) is called each time the
+// deployment moves to another step.
+func (c *Client) WaitForDeployment(ctx context.Context, id string, progress func(*Deployment)) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	// Subscribing first so that no event is missed during the first check
+	var events <-chan *Event
+	sub, err := c.SubscribeContext(ctx, EventDeploymentSuccess, EventDeploymentFailed,
+		EventDeploymentInfo, EventDeploymentStepSuccess, EventDeploymentStepFailure)
+	if err == nil {
+		defer sub.Close()
+		events = sub.Events()
+	}
+
+	step := -1
+
+	// deployment is the last state checked,
+	// telling the version deployed.
+	var deployment *Deployment
+
+	check := func() (bool, error) {
+		d, err := c.DeploymentContext(ctx, id)
+		if IsNotFound(err) {
+			return true, nil
+		}
+		if err != nil {
+			if IsRetryable(err) && ctx.Err() == nil {
+				return false, nil
+			}
+			return false, err
+		}
+
+		deployment = d
+
+		if progress != nil && d.CurrentStep != step {
+			step = d.CurrentStep
+			progress(d)
+		}
+
+		return false, nil
+	}
+
+	ticker := time.NewTicker(deploymentPollInterval)
+	defer ticker.Stop()
+
+	// over fires once the deployment left the list,
+	// its outcome event being waited for a while.
+	var over <-chan time.Time
+
+	for checking := true; ; {
+		if checking && over == nil {
+			done, err := check()
+			if err != nil {
+				return err
+			}
+
+			if done {
+				if events == nil {
+					return c.outcome(ctx, deployment)
+				}
+
+				over = time.After(deploymentOutcomeDelay)
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-over:
+			return c.outcome(ctx, deployment)
+		case event, ok := <-events:
+			if !ok {
+				// Polling marathon from now on
+				events, checking = nil, true
+				continue
+			}
+
+			data, ok := event.Data.(*DeploymentEvent)
+			if !ok || data.DeploymentID() != id {
+				checking = false
+				continue
+			}
+
+			switch event.Type {
+			case EventDeploymentSuccess:
+				return nil
+			case EventDeploymentFailed:
+				return ErrDeploymentFailed
+			}
+
+			checking = true
+		case <-ticker.C:
+			checking = true
+		}
+	}
+}
+
+// outcome tells the outcome of a deployment over from the applications
+// and pods it affected: they run the deployed version, or are destroyed
+// if the deployment stopped them, when it succeeded
+func (c *Client) outcome(ctx context.Context, d *Deployment) error {
+	if d == nil || d.Version == "" {
+		return ErrDeploymentUnknown
+	}
+
+	stopped := make(map[string]bool)
+	for _, step := range d.Steps {
+		for _, action := range step.Actions {
+			switch action.Action {
+			case "StopApplication":
+				stopped[action.App] = true
+			case "StopPod":
+				stopped[action.Pod] = true
+			}
+		}
+	}
+
+	deployed := func(id, version string, err error) error {
+		switch {
+		case IsNotFound(err) && stopped[id]:
+			return nil
+		case IsNotFound(err):
+			return ErrDeploymentFailed
+		case err != nil:
+			return err
+		case stopped[id] || version != d.Version:
+			return ErrDeploymentFailed
+		}
+
+		return nil
+	}
+
+	for _, id := range d.AffectedApps {
+		var version string
+
+		app, err := c.ApplicationContext(ctx, id)
+		if app != nil {
+			version = app.Version
+		}
+
+		if err := deployed(id, version, err); err != nil {
+			return err
+		}
+	}
+
+	for _, id := range d.AffectedPods {
+		var version string
+
+		pod, err := c.PodContext(ctx, id)
+		if pod != nil {
+			version = pod.Version
+		}
+
+		if err := deployed(id, version, err); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,302 @@
+package marathon
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/unrolled/render"
+)
+
+func newDeploymentServer(deployments func() []*Deployment, events []string, apps ...*Application) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, rq *http.Request) {
+		for _, app := range apps {
+			if rq.URL.Path == "/v2/apps"+app.ID {
+				render.New().JSON(rw, http.StatusOK, map[string]interface{}{"app": app})
+				return
+			}
+		}
+
+		switch rq.URL.Path {
+		case "/v2/deployments":
+			render.New().JSON(rw, http.StatusOK, deployments())
+		case "/v2/events":
+			if events == nil {
+				rw.WriteHeader(http.StatusNotFound)
+				return
+			}
+
+			rw.Header().Set("Content-Type", "text/event-stream")
+			rw.WriteHeader(http.StatusOK)
+
+			for _, event := range events {
+				fmt.Fprint(rw, event)
+			}
+			rw.(http.Flusher).Flush()
+
+			<-rq.Context().Done()
+		default:
+			rw.WriteHeader(http.StatusNotFound)
+		}
+	}))
+}
+
+func TestDeploymentsWithoutError(t *testing.T) {
+	assert := assert.New(t)
+
+	ts := newDeploymentServer(func() []*Deployment {
+		return []*Deployment{
+			{
+				ID:           "deployment-1",
+				AffectedApps: []string{"/test"},
+				CurrentActions: []*DeploymentAction{
+					{Action: "ScaleApplication", App: "/test"},
+				},
+				CurrentStep: 1,
+				TotalSteps:  2,
+			},
+		}
+	}, nil)
+	defer ts.Close()
+
+	client := NewClient(&Config{URI: ts.URL})
+
+	deployments, err := client.Deployments()
+	assert.NoError(err, "an unexpected error occured in deployments")
+
+	assert.Equal(1, len(deployments), "the number of deployments should be 1")
+	assert.Equal("ScaleApplication", deployments[0].CurrentActions[0].Action, "the actions should be equals")
+
+	deployment, err := client.Deployment("deployment-1")
+	assert.NoError(err, "an unexpected error occured in deployment")
+
+	assert.Equal(2, deployment.TotalSteps, "the number of steps should be 2")
+
+	_, err = client.Deployment("deployment-2")
+	assert.Error(err, "an error was expected in deployment")
+
+	assert.True(IsNotFound(err), "the error should be a not found error")
+}
+
+func TestDeleteDeploymentWithoutError(t *testing.T) {
+	assert := assert.New(t)
+
+	requests := make(chan *apiRequest, 1)
+
+	ts := newAPIServer(http.StatusOK, map[string]interface{}{
+		"deploymentId": "deployment-2",
+		"version":      "2019-01-01T00:00:00.000Z",
+	}, requests)
+	defer ts.Close()
+
+	client := NewClient(&Config{URI: ts.URL})
+
+	rollback, err := client.DeleteDeployment("deployment-1", false)
+	assert.NoError(err, "an unexpected error occured in deployment deletion")
+
+	rq := <-requests
+	assert.Equal("DELETE", rq.method, "the methods should be equals")
+	assert.Equal("/v2/deployments/deployment-1", rq.path, "the paths should be equals")
+	assert.Equal("", rq.query, "the queries should be equals")
+
+	assert.Equal("deployment-2", rollback.DeploymentID, "the deployment ids should be equals")
+
+	rollback, err = client.DeleteDeployment("deployment-1", true)
+	assert.NoError(err, "an unexpected error occured in forced deployment deletion")
+
+	rq = <-requests
+	assert.Equal("force=true", rq.query, "the queries should be equals")
+
+	assert.Nil(rollback, "the rollback deployment should be nil")
+}
+
+func TestWaitForDeploymentWithPolling(t *testing.T) {
+	assert := assert.New(t)
+
+	var checks int32
+
+	ts := newDeploymentServer(func() []*Deployment {
+		if atomic.AddInt32(&checks, 1) > 2 {
+			return []*Deployment{}
+		}
+
+		return []*Deployment{
+			{
+				ID:           "deployment-1",
+				Version:      "2019-01-01T00:00:00.000Z",
+				AffectedApps: []string{"/test"},
+				CurrentStep:  int(atomic.LoadInt32(&checks)),
+				TotalSteps:   2,
+			},
+		}
+	}, nil, &Application{ID: "/test", Version: "2019-01-01T00:00:00.000Z"})
+	defer ts.Close()
+
+	client := NewClient(&Config{URI: ts.URL})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	steps := []int{}
+
+	// The application runs the deployed version.
+	err := client.WaitForDeployment(ctx, "deployment-1", func(d *Deployment) {
+		steps = append(steps, d.CurrentStep)
+	})
+	assert.NoError(err, "an unexpected error occured in deployment wait")
+
+	assert.Equal([]int{1, 2}, steps, "the steps should be equals")
+}
+
+func TestWaitForDeploymentWithPollingWithErrorOnRollback(t *testing.T) {
+	assert := assert.New(t)
+
+	var checks int32
+
+	ts := newDeploymentServer(func() []*Deployment {
+		if atomic.AddInt32(&checks, 1) > 1 {
+			return []*Deployment{}
+		}
+
+		return []*Deployment{
+			{ID: "deployment-1", Version: "2019-01-01T00:00:00.000Z", AffectedApps: []string{"/test"}},
+		}
+	}, nil, &Application{ID: "/test", Version: "2019-01-01T00:01:00.000Z"})
+	defer ts.Close()
+
+	client := NewClient(&Config{URI: ts.URL})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	// The rollback replaced the deployed version.
+	err := client.WaitForDeployment(ctx, "deployment-1", nil)
+	assert.Equal(ErrDeploymentFailed, err, "the errors should be equals")
+}
+
+func TestWaitForDeploymentWithPollingOnStoppedApplication(t *testing.T) {
+	assert := assert.New(t)
+
+	var checks int32
+
+	ts := newDeploymentServer(func() []*Deployment {
+		if atomic.AddInt32(&checks, 1) > 1 {
+			return []*Deployment{}
+		}
+
+		return []*Deployment{
+			{
+				ID:           "deployment-1",
+				Version:      "2019-01-01T00:00:00.000Z",
+				AffectedApps: []string{"/test"},
+				Steps: []*DeploymentStep{
+					{Actions: []*DeploymentAction{{Action: "StopApplication", App: "/test"}}},
+				},
+			},
+		}
+	}, nil)
+	defer ts.Close()
+
+	client := NewClient(&Config{URI: ts.URL})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	// The application was destroyed as deployed.
+	err := client.WaitForDeployment(ctx, "deployment-1", nil)
+	assert.NoError(err, "an unexpected error occured in deployment wait")
+}
+
+func TestWaitForDeploymentWithErrorOnFailureEvent(t *testing.T) {
+	assert := assert.New(t)
+
+	var checks int32
+
+	ts := newDeploymentServer(func() []*Deployment {
+		atomic.AddInt32(&checks, 1)
+
+		return []*Deployment{
+			{ID: "deployment-1", CurrentStep: 1, TotalSteps: 1},
+		}
+	}, []string{
+		"event: deployment_info\ndata: {\"eventType\":\"deployment_info\",\"plan\":{\"id\":\"deployment-1\"}}\n\n",
+		"event: deployment_failed\ndata: {\"eventType\":\"deployment_failed\",\"id\":\"deployment-2\"}\n\n",
+		"event: deployment_failed\ndata: {\"eventType\":\"deployment_failed\",\"id\":\"deployment-1\"}\n\n",
+	})
+	defer ts.Close()
+
+	client := NewClient(&Config{URI: ts.URL})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	start := time.Now()
+
+	err := client.WaitForDeployment(ctx, "deployment-1", nil)
+	assert.Equal(ErrDeploymentFailed, err, "the errors should be equals")
+
+	assert.True(time.Since(start) < deploymentPollInterval, "the failure should be reported by the event stream")
+	assert.True(atomic.LoadInt32(&checks) <= 2, "the deployment should be checked on its own events only")
+}
+
+func TestWaitForDeploymentWithSuccessEvent(t *testing.T) {
+	assert := assert.New(t)
+
+	// The deployment leaves the list
+	// before its outcome is received.
+	ts := newDeploymentServer(func() []*Deployment {
+		return []*Deployment{}
+	}, []string{
+		"event: deployment_success\ndata: {\"eventType\":\"deployment_success\",\"id\":\"deployment-1\"}\n\n",
+	})
+	defer ts.Close()
+
+	client := NewClient(&Config{URI: ts.URL})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	err := client.WaitForDeployment(ctx, "deployment-1", nil)
+	assert.NoError(err, "an unexpected error occured in deployment wait")
+}
+
+func TestWaitForDeploymentWithErrorOnUnknownOutcome(t *testing.T) {
+	assert := assert.New(t)
+
+	ts := newDeploymentServer(func() []*Deployment {
+		return []*Deployment{}
+	}, []string{
+		"event: deployment_success\ndata: {\"eventType\":\"deployment_success\",\"id\":\"deployment-2\"}\n\n",
+	})
+	defer ts.Close()
+
+	client := NewClient(&Config{URI: ts.URL})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	err := client.WaitForDeployment(ctx, "deployment-1", nil)
+	assert.Equal(ErrDeploymentUnknown, err, "the errors should be equals")
+}
+
+func TestWaitForDeploymentWithErrorOnCancel(t *testing.T) {
+	assert := assert.New(t)
+
+	ts := newDeploymentServer(func() []*Deployment {
+		return []*Deployment{{ID: "deployment-1"}}
+	}, []string{})
+	defer ts.Close()
+
+	client := NewClient(&Config{URI: ts.URL})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+
+	err := client.WaitForDeployment(ctx, "deployment-1", nil)
+	assert.Equal(context.DeadlineExceeded, err, "the errors should be equals")
+}
@@ -1,31 +1,138 @@
 package marathon
 
 import (
+	"context"
 	"encoding/json"
+	"fmt"
 	"io/ioutil"
+	"net"
 	"net/http"
+	"net/url"
+	"strings"
 )
 
-// Error is a trivial implementation of error
+// maxErrorBodySize is the maximum size of a non json error body kept in
+// the error message
+const maxErrorBodySize = 256
+
+// Error is an error returned by marathon
 type Error struct {
-	message string `json:"message"`
+	// StatusCode is the http status code of the response
+	StatusCode int `json:"-"`
+	// Method is the http method of the request
+	Method string `json:"-"`
+	// Path is the path of the request
+	Path string `json:"-"`
+	// Message is the message returned by marathon
+	Message string `json:"message"`
+	// Details are the validation errors returned by marathon
+	Details []ErrorDetail `json:"details,omitempty"`
+}
+
+// ErrorDetail is a validation error on a marathon object attribute
+type ErrorDetail struct {
+	Path   string   `json:"path"`
+	Errors []string `json:"errors"`
 }
 
 // Error returns the description of the error
 func (e *Error) Error() string {
-	return e.message
+	msg := fmt.Sprintf("marathon: %s %s: %d %s", e.Method, e.Path, e.StatusCode, http.StatusText(e.StatusCode))
+
+	if e.Message != "" {
+		msg += ": " + e.Message
+	}
+
+	for _, d := range e.Details {
+		msg += fmt.Sprintf(" (%s: %s)", d.Path, strings.Join(d.Errors, ", "))
+	}
+
+	return msg
 }
 
 func parseError(resp *http.Response) error {
+	e := &Error{
+		StatusCode: resp.StatusCode,
+	}
+
+	if resp.Request != nil {
+		e.Method = resp.Request.Method
+		e.Path = resp.Request.URL.Path
+	}
+
 	b, err := ioutil.ReadAll(resp.Body)
 	if err != nil {
-		return err
+		return e
+	}
+
+	var body struct {
+		Error
+		// DC/OS errors carry a description instead of a message
+		Description string `json:"description"`
+	}
+
+	if err := json.Unmarshal(b, &body); err != nil {
+		// Non json bodies (e.g. proxies html pages)
+		e.Message = strings.TrimSpace(string(b))
+		if len(e.Message) > maxErrorBodySize {
+			e.Message = e.Message[:maxErrorBodySize] + "..."
+		}
+		return e
+	}
+
+	e.Message = body.Message
+	if e.Message == "" {
+		e.Message = body.Description
 	}
+	e.Details = body.Details
+
+	return e
+}
+
+// IsNotFound returns true if the error is a marathon not found error
+func IsNotFound(err error) bool {
+	return hasStatus(err, http.StatusNotFound)
+}
+
+// IsUnauthorized returns true if the error is a marathon authentication
+// or authorization error
+func IsUnauthorized(err error) bool {
+	return hasStatus(err, http.StatusUnauthorized) || hasStatus(err, http.StatusForbidden)
+}
+
+// IsConflict returns true if the error is a marathon conflict error
+// (e.g. an application locked by a deployment)
+func IsConflict(err error) bool {
+	return hasStatus(err, http.StatusConflict)
+}
 
-	err = &Error{}
-	if err := json.Unmarshal(b, err); err != nil {
-		return err
+// IsRetryable returns true if the request failing with the error may
+// succeed when sent again: network errors, timeouts, throttling and
+// marathon server errors.
+func IsRetryable(err error) bool {
+	if e, ok := err.(*url.Error); ok {
+		err = e.Err
 	}
 
-	return err
+	if err == nil || err == context.Canceled {
+		return false
+	}
+
+	if e, ok := err.(*Error); ok {
+		return e.StatusCode >= 500 ||
+			e.StatusCode == http.StatusRequestTimeout ||
+			e.StatusCode == http.StatusTooManyRequests
+	}
+
+	if err == context.DeadlineExceeded {
+		return true
+	}
+
+	_, ok := err.(net.Error)
+	return ok
+}
+
+func hasStatus(err error, status int) bool {
+	e, ok := err.(*Error)
+	return ok && e.StatusCode == status
 }
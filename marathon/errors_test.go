@@ -0,0 +1,91 @@
+package marathon
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func newErrorServer(status int, contentType, body string) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, rq *http.Request) {
+		rw.Header().Set("Content-Type", contentType)
+		rw.WriteHeader(status)
+		fmt.Fprint(rw, body)
+	}))
+}
+
+func TestErrorWithDetails(t *testing.T) {
+	assert := assert.New(t)
+
+	ts := newErrorServer(http.StatusUnprocessableEntity, "application/json",
+		`{"message":"Object is not valid","details":[{"path":"/id","errors":["error.pattern"]}]}`)
+	defer ts.Close()
+
+	client := NewClient(&Config{URI: ts.URL})
+
+	_, err := client.Applications("service-test")
+	assert.Error(err, "an error was expected in applications")
+
+	e, ok := err.(*Error)
+	assert.True(ok, "the error should be a marathon error")
+	assert.Equal(http.StatusUnprocessableEntity, e.StatusCode, "the status codes should be equals")
+	assert.Equal("GET", e.Method, "the methods should be equals")
+	assert.Equal("/v2/apps", e.Path, "the paths should be equals")
+	assert.Equal("Object is not valid", e.Message, "the messages should be equals")
+	assert.Equal([]ErrorDetail{{Path: "/id", Errors: []string{"error.pattern"}}}, e.Details, "the details should be equals")
+	assert.Contains(e.Error(), "/id: error.pattern", "the details should be described")
+	assert.False(IsRetryable(err), "the error shouldn't be retryable")
+}
+
+func TestErrorWithNonJSONBody(t *testing.T) {
+	assert := assert.New(t)
+
+	ts := newErrorServer(http.StatusBadGateway, "text/html", "<html><body>502 Bad Gateway</body></html>")
+	defer ts.Close()
+
+	client := NewClient(&Config{URI: ts.URL})
+
+	err := client.Ping()
+	assert.Error(err, "an error was expected in ping")
+
+	e, ok := err.(*Error)
+	assert.True(ok, "the error should be a marathon error")
+	assert.Equal(http.StatusBadGateway, e.StatusCode, "the status codes should be equals")
+	assert.True(strings.Contains(e.Message, "502 Bad Gateway"), "the body should be kept in the message")
+	assert.True(IsRetryable(err), "the error should be retryable")
+}
+
+func TestErrorHelpers(t *testing.T) {
+	assert := assert.New(t)
+
+	assert.True(IsNotFound(&Error{StatusCode: http.StatusNotFound}), "the error should be a not found error")
+	assert.True(IsUnauthorized(&Error{StatusCode: http.StatusUnauthorized}), "the error should be an unauthorized error")
+	assert.True(IsUnauthorized(&Error{StatusCode: http.StatusForbidden}), "the error should be an unauthorized error")
+	assert.True(IsConflict(&Error{StatusCode: http.StatusConflict}), "the error should be a conflict error")
+	assert.True(IsRetryable(&Error{StatusCode: http.StatusServiceUnavailable}), "the error should be retryable")
+	assert.True(IsRetryable(&Error{StatusCode: http.StatusTooManyRequests}), "the error should be retryable")
+	assert.True(IsRetryable(context.DeadlineExceeded), "the error should be retryable")
+
+	assert.False(IsNotFound(fmt.Errorf("not found")), "the error shouldn't be a not found error")
+	assert.False(IsRetryable(&Error{StatusCode: http.StatusBadRequest}), "the error shouldn't be retryable")
+	assert.False(IsRetryable(context.Canceled), "the error shouldn't be retryable")
+	assert.False(IsRetryable(nil), "a nil error shouldn't be retryable")
+}
+
+func TestErrorOnConnectionRefused(t *testing.T) {
+	assert := assert.New(t)
+
+	ts := httptest.NewServer(http.NotFoundHandler())
+	ts.Close()
+
+	client := NewClient(&Config{URI: ts.URL})
+
+	err := client.Ping()
+	assert.Error(err, "an error was expected in ping")
+	assert.True(IsRetryable(err), "the error should be retryable")
+}
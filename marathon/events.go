@@ -0,0 +1,384 @@
+package marathon
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// Event types emitted by marathon on the event stream
+const (
+	EventStreamAttached        = "event_stream_attached"
+	EventStreamDetached        = "event_stream_detached"
+	EventStatusUpdate          = "status_update_event"
+	EventHealthStatusChanged   = "health_status_changed_event"
+	EventInstanceChanged       = "instance_changed_event"
+	EventInstanceHealthChanged = "instance_health_changed_event"
+	EventAPIPost               = "api_post_event"
+	EventAppTerminated         = "app_terminated_event"
+	EventPodCreated            = "pod_created_event"
+	EventPodUpdated            = "pod_updated_event"
+	EventPodDeleted            = "pod_deleted_event"
+	EventDeploymentSuccess     = "deployment_success"
+	EventDeploymentFailed      = "deployment_failed"
+	EventDeploymentInfo        = "deployment_info"
+	EventDeploymentStepSuccess = "deployment_step_success"
+	EventDeploymentStepFailure = "deployment_step_failure"
+)
+
+const (
+	minReconnectInterval = 500 * time.Millisecond
+	maxReconnectInterval = 30 * time.Second
+)
+
+// Event represents an event received from the marathon event stream.
+// Data holds the typed event (e.g. *StatusUpdateEvent) or the raw json
+// message for event types unknown by the client.
+type Event struct {
+	Type string
+	Data interface{}
+}
+
+// StreamEvent is sent by marathon when a subscriber attaches to or
+// detaches from the event stream
+type StreamEvent struct {
+	EventType     string `json:"eventType"`
+	Timestamp     string `json:"timestamp"`
+	RemoteAddress string `json:"remoteAddress"`
+}
+
+// StatusUpdateEvent is sent by marathon each time a task changes its state
+type StatusUpdateEvent struct {
+	EventType   string      `json:"eventType"`
+	Timestamp   string      `json:"timestamp"`
+	SlaveID     string      `json:"slaveId"`
+	TaskID      string      `json:"taskId"`
+	TaskStatus  string      `json:"taskStatus"`
+	Message     string      `json:"message"`
+	AppID       string      `json:"appId"`
+	Host        string      `json:"host"`
+	Ports       []int       `json:"ports"`
+	IPAddresses []IPAddress `json:"ipAddresses"`
+	Version     string      `json:"version"`
+}
+
+// HealthStatusChangedEvent is sent by marathon when the result of a
+// task's health check changes
+type HealthStatusChangedEvent struct {
+	EventType  string `json:"eventType"`
+	Timestamp  string `json:"timestamp"`
+	AppID      string `json:"appId"`
+	TaskID     string `json:"taskId"`
+	InstanceID string `json:"instanceId"`
+	Version    string `json:"version"`
+	Alive      bool   `json:"alive"`
+}
+
+// InstanceChangedEvent is sent by marathon each time an instance of an
+// application or a pod changes its condition
+type InstanceChangedEvent struct {
+	EventType      string `json:"eventType"`
+	Timestamp      string `json:"timestamp"`
+	InstanceID     string `json:"instanceId"`
+	Condition      string `json:"condition"`
+	RunSpecID      string `json:"runSpecId"`
+	RunSpecVersion string `json:"runSpecVersion"`
+	AgentID        string `json:"agentId"`
+	Host           string `json:"host"`
+}
+
+// InstanceHealthChangedEvent is sent by marathon when the health of an
+// instance of an application or a pod changes
+type InstanceHealthChangedEvent struct {
+	EventType      string `json:"eventType"`
+	Timestamp      string `json:"timestamp"`
+	InstanceID     string `json:"instanceId"`
+	RunSpecID      string `json:"runSpecId"`
+	RunSpecVersion string `json:"runSpecVersion"`
+	Healthy        *bool  `json:"healthy"`
+}
+
+// APIPostEvent is sent by marathon when an application is created or
+// updated through the api
+type APIPostEvent struct {
+	EventType     string       `json:"eventType"`
+	Timestamp     string       `json:"timestamp"`
+	ClientIP      string       `json:"clientIp"`
+	URI           string       `json:"uri"`
+	AppDefinition *Application `json:"appDefinition"`
+}
+
+// AppTerminatedEvent is sent by marathon when an application is destroyed
+type AppTerminatedEvent struct {
+	EventType string `json:"eventType"`
+	Timestamp string `json:"timestamp"`
+	AppID     string `json:"appId"`
+}
+
+// PodEvent is sent by marathon when a pod is created, updated or deleted
+type PodEvent struct {
+	EventType string `json:"eventType"`
+	Timestamp string `json:"timestamp"`
+	ClientIP  string `json:"clientIp"`
+	URI       string `json:"uri"`
+}
+
+// DeploymentEvent is sent by marathon during the lifecycle of a
+// deployment. The step events carry the deployment in the plan only.
+type DeploymentEvent struct {
+	EventType   string          `json:"eventType"`
+	Timestamp   string          `json:"timestamp"`
+	ID          string          `json:"id"`
+	Plan        *DeploymentPlan `json:"plan,omitempty"`
+	CurrentStep *DeploymentStep `json:"currentStep,omitempty"`
+}
+
+// DeploymentID returns the id of the deployment concerned by the event
+func (e *DeploymentEvent) DeploymentID() string {
+	if e.ID == "" && e.Plan != nil {
+		return e.Plan.ID
+	}
+
+	return e.ID
+}
+
+// AppID returns the application (or pod) id concerned by the event if any
+func (e *Event) AppID() string {
+	switch data := e.Data.(type) {
+	case *StatusUpdateEvent:
+		return data.AppID
+	case *HealthStatusChangedEvent:
+		return data.AppID
+	case *InstanceChangedEvent:
+		return data.RunSpecID
+	case *InstanceHealthChangedEvent:
+		return data.RunSpecID
+	case *APIPostEvent:
+		if data.AppDefinition != nil {
+			return data.AppDefinition.ID
+		}
+	case *AppTerminatedEvent:
+		return data.AppID
+	}
+
+	return ""
+}
+
+func decodeEvent(eventType string, b []byte) (*Event, error) {
+	var data interface{}
+
+	switch eventType {
+	case EventStreamAttached, EventStreamDetached:
+		data = &StreamEvent{}
+	case EventStatusUpdate:
+		data = &StatusUpdateEvent{}
+	case EventHealthStatusChanged:
+		data = &HealthStatusChangedEvent{}
+	case EventInstanceChanged:
+		data = &InstanceChangedEvent{}
+	case EventInstanceHealthChanged:
+		data = &InstanceHealthChangedEvent{}
+	case EventAPIPost:
+		data = &APIPostEvent{}
+	case EventAppTerminated:
+		data = &AppTerminatedEvent{}
+	case EventPodCreated, EventPodUpdated, EventPodDeleted:
+		data = &PodEvent{}
+	case EventDeploymentSuccess, EventDeploymentFailed, EventDeploymentInfo,
+		EventDeploymentStepSuccess, EventDeploymentStepFailure:
+		data = &DeploymentEvent{}
+	default:
+		return &Event{Type: eventType, Data: json.RawMessage(b)}, nil
+	}
+
+	if err := json.Unmarshal(b, data); err != nil {
+		return nil, err
+	}
+
+	return &Event{Type: eventType, Data: data}, nil
+}
+
+// Subscription is a subscription to the marathon event stream. It
+// reconnects automatically when the stream is interrupted.
+type Subscription struct {
+	client *Client
+	path   string
+	types  map[string]bool
+	events chan *Event
+	ctx    context.Context
+	cancel context.CancelFunc
+}
+
+// Subscribe opens the marathon event stream and returns a subscription
+// receiving the events of the given types (every events if no type is
+// given). An error is returned if the first connection fails.
+func (c *Client) Subscribe(types ...string) (*Subscription, error) {
+	return c.SubscribeContext(context.Background(), types...)
+}
+
+// SubscribeContext opens the marathon event stream and returns a
+// subscription receiving the events of the given types (every events if
+// no type is given). The subscription is closed when the context is
+// done. An error is returned if the first connection fails.
+func (c *Client) SubscribeContext(ctx context.Context, types ...string) (*Subscription, error) {
+	params := url.Values{}
+	filter := make(map[string]bool)
+	for _, t := range types {
+		params.Add("event_type", t)
+		filter[t] = true
+	}
+
+	path := "/v2/events"
+	if len(params) > 0 {
+		path += "?" + params.Encode()
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+
+	s := &Subscription{
+		client: c,
+		path:   path,
+		types:  filter,
+		events: make(chan *Event, 16),
+		ctx:    ctx,
+		cancel: cancel,
+	}
+
+	body, err := s.connect()
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+
+	go s.run(body)
+
+	return s, nil
+}
+
+// Events returns the channel on which the events are delivered. The
+// channel is closed when the subscription is closed.
+func (s *Subscription) Events() <-chan *Event {
+	return s.events
+}
+
+// Close closes the subscription and the underlying stream
+func (s *Subscription) Close() {
+	s.cancel()
+}
+
+func (s *Subscription) connect() (io.ReadCloser, error) {
+	resp, err := s.client.do(s.ctx, "GET", s.path, nil, http.Header{
+		"Accept": []string{"text/event-stream"},
+	}, s.client.config.RequestTimeout, true)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode >= 400 {
+		defer resp.Body.Close()
+		return nil, parseError(resp)
+	}
+
+	mediaType, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	if mediaType != "text/event-stream" {
+		_ = resp.Body.Close()
+		return nil, fmt.Errorf("unexpected event stream content type %q", mediaType)
+	}
+
+	return resp.Body, nil
+}
+
+// run reads the stream until the subscription is closed, reconnecting
+// with an exponential backoff each time the stream is interrupted
+func (s *Subscription) run(body io.ReadCloser) {
+	defer close(s.events)
+
+	backoff := minReconnectInterval
+
+	for {
+		if body != nil {
+			s.read(body)
+			_ = body.Close()
+			backoff = minReconnectInterval
+		}
+
+		select {
+		case <-s.ctx.Done():
+			return
+		case <-time.After(backoff):
+		}
+
+		var err error
+		body, err = s.connect()
+		if err != nil {
+			backoff *= 2
+			if backoff > maxReconnectInterval {
+				backoff = maxReconnectInterval
+			}
+		}
+	}
+}
+
+// read decodes the server-sent events until the stream ends
+func (s *Subscription) read(body io.Reader) {
+	r := bufio.NewReader(body)
+
+	var eventType string
+	var data []string
+
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return
+		}
+
+		line = strings.TrimRight(line, "\r\n")
+
+		switch {
+		case line == "":
+			if len(data) > 0 {
+				s.dispatch(eventType, []byte(strings.Join(data, "\n")))
+			}
+			eventType, data = "", nil
+		case strings.HasPrefix(line, ":"):
+			// Comment line used as keep-alive
+		case strings.HasPrefix(line, "event:"):
+			eventType = strings.TrimSpace(strings.TrimPrefix(line, "event:"))
+		case strings.HasPrefix(line, "data:"):
+			data = append(data, strings.TrimPrefix(strings.TrimPrefix(line, "data:"), " "))
+		}
+	}
+}
+
+func (s *Subscription) dispatch(eventType string, b []byte) {
+	if eventType == "" {
+		var header struct {
+			EventType string `json:"eventType"`
+		}
+		if err := json.Unmarshal(b, &header); err != nil {
+			return
+		}
+		eventType = header.EventType
+	}
+
+	if len(s.types) > 0 && !s.types[eventType] {
+		return
+	}
+
+	event, err := decodeEvent(eventType, b)
+	if err != nil {
+		return
+	}
+
+	select {
+	case s.events <- event:
+	case <-s.ctx.Done():
+	}
+}
@@ -0,0 +1,259 @@
+package marathon
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// newEventServer returns a server streaming the given payloads, one per
+// connection. The stream of the last connection is kept open.
+func newEventServer(payloads ...string) (*httptest.Server, chan *http.Request, *int32) {
+	requests := make(chan *http.Request, len(payloads)+1)
+	conns := new(int32)
+
+	ts := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, rq *http.Request) {
+		// The leader lookups are ignored.
+		if rq.URL.Path != "/v2/events" {
+			rw.WriteHeader(http.StatusNotFound)
+			return
+		}
+
+		n := int(atomic.AddInt32(conns, 1))
+
+		select {
+		case requests <- rq:
+		default:
+		}
+
+		rw.Header().Set("Content-Type", "text/event-stream; charset=utf-8")
+		rw.WriteHeader(http.StatusOK)
+
+		if n <= len(payloads) {
+			_, _ = rw.Write([]byte(payloads[n-1]))
+		}
+		rw.(http.Flusher).Flush()
+
+		if n >= len(payloads) {
+			<-rq.Context().Done()
+		}
+	}))
+
+	return ts, requests, conns
+}
+
+func nextEvent(t *testing.T, events <-chan *Event) *Event {
+	select {
+	case event := <-events:
+		return event
+	case <-time.After(5 * time.Second):
+		t.Fatal("no event received")
+	}
+
+	return nil
+}
+
+func TestSubscribeWithoutError(t *testing.T) {
+	assert := assert.New(t)
+
+	ts, requests, _ := newEventServer(
+		": keep-alive\r\n" +
+			"event: status_update_event\r\n" +
+			`data: {"eventType":"status_update_event","taskId":"test.1","taskStatus":"TASK_RUNNING","appId":"/test","host":"10.0.0.1","ports":[31000]}` + "\r\n" +
+			"\r\n",
+	)
+	defer ts.Close()
+
+	client := NewClient(&Config{URI: ts.URL})
+
+	sub, err := client.Subscribe(EventStatusUpdate, EventAppTerminated)
+	assert.NoError(err, "an unexpected error occured in subscribe")
+
+	defer sub.Close()
+
+	rq := <-requests
+	assert.Equal("/v2/events", rq.URL.Path, "the paths should be equals")
+	assert.Equal("event_type=status_update_event&event_type=app_terminated_event", rq.URL.RawQuery, "the queries should be equals")
+	assert.Equal("text/event-stream", rq.Header.Get("Accept"), "the accept headers should be equals")
+
+	event := nextEvent(t, sub.Events())
+	assert.Equal(EventStatusUpdate, event.Type, "the event types should be equals")
+	assert.Equal("/test", event.AppID(), "the application ids should be equals")
+
+	data, ok := event.Data.(*StatusUpdateEvent)
+	assert.True(ok, "the event should be a status update")
+	assert.Equal("test.1", data.TaskID, "the task ids should be equals")
+	assert.Equal("TASK_RUNNING", data.TaskStatus, "the task statuses should be equals")
+	assert.Equal([]int{31000}, data.Ports, "the ports should be equals")
+}
+
+func TestSubscribeWithMultilineData(t *testing.T) {
+	assert := assert.New(t)
+
+	// The event type is read from the data
+	// when there is no event line.
+	ts, _, _ := newEventServer(
+		"data: {\n" +
+			`data: "eventType": "app_terminated_event",` + "\n" +
+			`data: "appId": "/test"` + "\n" +
+			"data: }\n" +
+			"\n",
+	)
+	defer ts.Close()
+
+	client := NewClient(&Config{URI: ts.URL})
+
+	sub, err := client.Subscribe()
+	assert.NoError(err, "an unexpected error occured in subscribe")
+
+	defer sub.Close()
+
+	event := nextEvent(t, sub.Events())
+	assert.Equal(EventAppTerminated, event.Type, "the event types should be equals")
+
+	data, ok := event.Data.(*AppTerminatedEvent)
+	assert.True(ok, "the event should be an application termination")
+	assert.Equal("/test", data.AppID, "the application ids should be equals")
+}
+
+func TestSubscribeWithTypeFilter(t *testing.T) {
+	assert := assert.New(t)
+
+	ts, _, _ := newEventServer(
+		"event: deployment_info\n" +
+			`data: {"eventType":"deployment_info","plan":{"id":"deployment-1"}}` + "\n\n" +
+			"event: unknown_event\n" +
+			`data: {"eventType":"unknown_event"}` + "\n\n" +
+			"event: app_terminated_event\n" +
+			`data: {"eventType":"app_terminated_event","appId":"/test"}` + "\n\n",
+	)
+	defer ts.Close()
+
+	client := NewClient(&Config{URI: ts.URL})
+
+	sub, err := client.Subscribe(EventAppTerminated)
+	assert.NoError(err, "an unexpected error occured in subscribe")
+
+	defer sub.Close()
+
+	event := nextEvent(t, sub.Events())
+	assert.Equal(EventAppTerminated, event.Type, "the event types should be equals")
+	assert.Equal("/test", event.AppID(), "the application ids should be equals")
+
+	select {
+	case event := <-sub.Events():
+		t.Fatalf("unexpected %s event", event.Type)
+	case <-time.After(100 * time.Millisecond):
+	}
+}
+
+func TestSubscribeReconnectOnEOF(t *testing.T) {
+	assert := assert.New(t)
+
+	ts, _, conns := newEventServer(
+		`data: {"eventType":"app_terminated_event","appId":"/first"}`+"\n\n",
+		`data: {"eventType":"app_terminated_event","appId":"/second"}`+"\n\n",
+	)
+	defer ts.Close()
+
+	client := NewClient(&Config{URI: ts.URL})
+
+	sub, err := client.Subscribe()
+	assert.NoError(err, "an unexpected error occured in subscribe")
+
+	event := nextEvent(t, sub.Events())
+	assert.Equal("/first", event.AppID(), "the application ids should be equals")
+
+	start := time.Now()
+
+	event = nextEvent(t, sub.Events())
+	assert.Equal("/second", event.AppID(), "the application ids should be equals")
+
+	assert.True(time.Since(start) >= minReconnectInterval/2, "the subscription should back off before reconnecting")
+	assert.Equal(int32(2), atomic.LoadInt32(conns), "the number of connections should be 2")
+
+	sub.Close()
+
+	select {
+	case _, ok := <-sub.Events():
+		assert.False(ok, "the events channel should be closed")
+	case <-time.After(5 * time.Second):
+		t.Fatal("the events channel was not closed")
+	}
+}
+
+func TestSubscribeWithErrorOnContentType(t *testing.T) {
+	assert := assert.New(t)
+
+	ts := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, rq *http.Request) {
+		rw.Header().Set("Content-Type", "application/json")
+		_, _ = rw.Write([]byte(`{}`))
+	}))
+	defer ts.Close()
+
+	client := NewClient(&Config{URI: ts.URL})
+
+	sub, err := client.Subscribe()
+	assert.Error(err, "an error was expected in subscribe")
+
+	assert.Nil(sub, "the subscription should be nil")
+}
+
+func TestSubscribeFailoverOnRequestTimeout(t *testing.T) {
+	assert := assert.New(t)
+
+	hung, release := newHangingServer()
+	defer hung.Close()
+	defer close(release)
+
+	healthy, _, conns := newEventServer(
+		`data: {"eventType":"app_terminated_event","appId":"/test"}` + "\n\n",
+	)
+	defer healthy.Close()
+
+	client := NewClient(&Config{
+		URI:            hung.URL,
+		Endpoints:      []string{healthy.URL},
+		RequestTimeout: 200 * time.Millisecond,
+	})
+
+	sub, err := client.Subscribe()
+	assert.NoError(err, "an unexpected error occured in subscribe")
+
+	defer sub.Close()
+
+	assert.Equal(healthy.URL, client.URI(), "the hung instance should be marked down")
+
+	event := nextEvent(t, sub.Events())
+	assert.Equal("/test", event.AppID(), "the application ids should be equals")
+
+	// The stream outlives the request timeout.
+	time.Sleep(time.Second)
+
+	assert.Equal(int32(1), atomic.LoadInt32(conns), "the number of connections should be 1")
+}
+
+func TestSubscribeWithErrorOnRequestTimeout(t *testing.T) {
+	assert := assert.New(t)
+
+	hung, release := newHangingServer()
+	defer hung.Close()
+	defer close(release)
+
+	client := NewClient(&Config{
+		URI:            hung.URL,
+		RequestTimeout: 200 * time.Millisecond,
+	})
+
+	start := time.Now()
+
+	sub, err := client.Subscribe()
+	assert.Error(err, "an error was expected in subscribe")
+
+	assert.Nil(sub, "the subscription should be nil")
+	assert.True(time.Since(start) < time.Second, "the connection should time out")
+}
@@ -0,0 +1,73 @@
+package marathon
+
+import (
+	"context"
+	"strings"
+)
+
+// Group represents a marathon group of applications, pods and groups
+type Group struct {
+	ID           string         `json:"id"`
+	Apps         []*Application `json:"apps,omitempty"`
+	Pods         []*Pod         `json:"pods,omitempty"`
+	Groups       []*Group       `json:"groups,omitempty"`
+	Dependencies []string       `json:"dependencies,omitempty"`
+	Version      string         `json:"version,omitempty"`
+}
+
+// Group returns a group given its id, including its nested groups. The
+// root group id is "/".
+func (c *Client) Group(id string) (*Group, error) {
+	return c.GroupContext(context.Background(), id)
+}
+
+// GroupContext returns a group given its id, including its nested
+// groups. The root group id is "/".
+func (c *Client) GroupContext(ctx context.Context, id string) (*Group, error) {
+	group := &Group{}
+
+	if err := c.apiCall(ctx, "GET", groupPath(id), nil, group); err != nil {
+		return nil, err
+	}
+
+	return group, nil
+}
+
+// CreateGroup creates a group with its applications, pods and groups and
+// returns the deployment starting them
+func (c *Client) CreateGroup(group *Group) (*DeploymentID, error) {
+	return c.CreateGroupContext(context.Background(), group)
+}
+
+// CreateGroupContext creates a group with its applications, pods and
+// groups and returns the deployment starting them
+func (c *Client) CreateGroupContext(ctx context.Context, group *Group) (*DeploymentID, error) {
+	return c.change(ctx, "POST", "/v2/groups", group)
+}
+
+// UpdateGroup replaces a group definition. The force flag overrides the
+// deployment in progress which locks the group.
+func (c *Client) UpdateGroup(group *Group, force bool) (*DeploymentID, error) {
+	return c.UpdateGroupContext(context.Background(), group, force)
+}
+
+// UpdateGroupContext replaces a group definition. The force flag
+// overrides the deployment in progress which locks the group.
+func (c *Client) UpdateGroupContext(ctx context.Context, group *Group, force bool) (*DeploymentID, error) {
+	return c.change(ctx, "PUT", withParams(groupPath(group.ID), forceParams(force)), group)
+}
+
+// DeleteGroup destroys a group and everything it contains
+func (c *Client) DeleteGroup(id string, force bool) (*DeploymentID, error) {
+	return c.DeleteGroupContext(context.Background(), id, force)
+}
+
+// DeleteGroupContext destroys a group and everything it contains
+func (c *Client) DeleteGroupContext(ctx context.Context, id string, force bool) (*DeploymentID, error) {
+	return c.change(ctx, "DELETE", withParams(groupPath(id), forceParams(force)), nil)
+}
+
+// groupPath returns the api path of a group given its id
+func groupPath(id string) string {
+	return "/v2/groups/" + strings.Trim(id, "/")
+}
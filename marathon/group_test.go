@@ -0,0 +1,80 @@
+package marathon
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGroupWithoutError(t *testing.T) {
+	assert := assert.New(t)
+
+	requests := make(chan *apiRequest, 1)
+
+	ts := newAPIServer(http.StatusOK, map[string]interface{}{
+		"id": "/prod/payments",
+		"apps": []map[string]interface{}{
+			{"id": "/prod/payments/api"},
+		},
+		"groups": []map[string]interface{}{
+			{"id": "/prod/payments/workers"},
+		},
+	}, requests)
+	defer ts.Close()
+
+	client := NewClient(&Config{URI: ts.URL})
+
+	group, err := client.Group("/prod/payments")
+	assert.NoError(err, "an unexpected error occured in group")
+
+	rq := <-requests
+	assert.Equal("GET", rq.method, "the methods should be equals")
+	assert.Equal("/v2/groups/prod/payments", rq.path, "the paths should be equals")
+
+	assert.Equal("/prod/payments/api", group.Apps[0].ID, "the application ids should be equals")
+	assert.Equal("/prod/payments/workers", group.Groups[0].ID, "the group ids should be equals")
+}
+
+func TestGroupChangesWithoutError(t *testing.T) {
+	assert := assert.New(t)
+
+	requests := make(chan *apiRequest, 1)
+
+	ts := newAPIServer(http.StatusOK, map[string]interface{}{
+		"deploymentId": "deployment-1",
+		"version":      "2019-01-01T00:00:00.000Z",
+	}, requests)
+	defer ts.Close()
+
+	client := NewClient(&Config{URI: ts.URL})
+
+	group := &Group{
+		ID:   "/prod/payments",
+		Apps: []*Application{{ID: "/prod/payments/api", Cmd: "sleep 100"}},
+	}
+
+	tests := []struct {
+		name   string
+		call   func() (*DeploymentID, error)
+		method string
+		path   string
+		query  string
+	}{
+		{"creation", func() (*DeploymentID, error) { return client.CreateGroup(group) }, "POST", "/v2/groups", ""},
+		{"update", func() (*DeploymentID, error) { return client.UpdateGroup(group, true) }, "PUT", "/v2/groups/prod/payments", "force=true"},
+		{"deletion", func() (*DeploymentID, error) { return client.DeleteGroup("/prod/payments", false) }, "DELETE", "/v2/groups/prod/payments", ""},
+	}
+
+	for _, test := range tests {
+		deployment, err := test.call()
+		assert.NoError(err, "an unexpected error occured in group "+test.name)
+
+		rq := <-requests
+		assert.Equal(test.method, rq.method, "the methods should be equals")
+		assert.Equal(test.path, rq.path, "the paths should be equals")
+		assert.Equal(test.query, rq.query, "the queries should be equals")
+
+		assert.Equal("deployment-1", deployment.DeploymentID, "the deployment ids should be equals")
+	}
+}
@@ -1,9 +1,17 @@
 package marathon
 
-import "fmt"
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"time"
+)
 
 type Client struct {
-	config *Config
+	config  *Config
+	cluster *cluster
+	tokens  *tokenSource
+	agents  *agentCache
 }
 
 // Config represents the marathon client configuration object
@@ -12,22 +20,62 @@ type Config struct {
 	HTTPBasicAuthPassword string
 	DCOSToken             string
 	URI                   string
+	// Endpoints are the uris of the other marathon instances of the
+	// cluster. The requests fail over between URI and Endpoints.
+	Endpoints []string
+	// HTTPClient is the client sending the requests (default: http.DefaultClient)
+	HTTPClient *http.Client
+	// ServiceAccount is the DC/OS service account used to log in. It
+	// takes precedence over DCOSToken.
+	ServiceAccount *ServiceAccount
+	// RequestTimeout is the time allowed to each api call on each
+	// marathon instance, event streams excepted. A timed out instance
+	// is marked down and the call fails over (default: no timeout).
+	RequestTimeout time.Duration
+	// MesosURI is the uri of the mesos master queried for the agents
+	// attributes (e.g. https://leader.mesos:5050 or https://dcos/mesos)
+	MesosURI string
+	// AgentCacheTTL is the time an agent is cached before it is requested
+	// again (default: 10m)
+	AgentCacheTTL time.Duration
+	// Observer is notified of each request (default: none)
+	Observer Observer
 }
 
 // NewClient instantiates a new marathon client
 func NewClient(config *Config) *Client {
-	return &Client{
-		config: config,
+	c := &Client{
+		config:  config,
+		cluster: newCluster(append([]string{config.URI}, config.Endpoints...)),
+		agents:  newAgentCache(config.AgentCacheTTL),
 	}
+
+	if config.ServiceAccount != nil {
+		c.tokens = newTokenSource(c, config.ServiceAccount)
+	}
+
+	return c
 }
 
 // Applications returns a set of applications according to a label
+// selector (e.g. "RESOLVER_0_NAME==service"), all of them if it is empty.
+// The selector matches the application labels only, not the labels of
+// the ports.
 func (c *Client) Applications(label string) ([]*Application, error) {
+	return c.ApplicationsContext(context.Background(), label)
+}
+
+// ApplicationsContext returns a set of applications according to a label
+// selector, all of them if it is empty
+func (c *Client) ApplicationsContext(ctx context.Context, label string) ([]*Application, error) {
 	apps := []*Application{}
 
-	path := fmt.Sprintf("/v2/apps?label=%s", label)
+	path := "/v2/apps"
+	if label != "" {
+		path += "?label=" + url.QueryEscape(label)
+	}
 
-	if err := c.apiCall("GET", path, nil, &apps); err != nil {
+	if err := c.apiCall(ctx, "GET", path, nil, &apps); err != nil {
 		return nil, err
 	}
 
@@ -36,21 +84,76 @@ func (c *Client) Applications(label string) ([]*Application, error) {
 
 // Tasks returns a specific application's set of tasks
 func (c *Client) Tasks(appID string) ([]*Task, error) {
-	tasks := []*Task{}
+	return c.TasksContext(context.Background(), appID)
+}
 
-	if err := c.apiCall("GET", "/v2/tasks", nil, &tasks); err != nil {
+// TasksContext returns a specific application's set of tasks
+func (c *Client) TasksContext(ctx context.Context, appID string) ([]*Task, error) {
+	var result struct {
+		Tasks []*Task `json:"tasks"`
+	}
+
+	path := appPath(appID) + "/tasks"
+
+	if err := c.apiCall(ctx, "GET", path, nil, &result); err != nil {
 		return nil, err
 	}
 
-	return tasks, nil
+	if result.Tasks == nil {
+		return []*Task{}, nil
+	}
+
+	return result.Tasks, nil
 }
 
 // Ping returns an error if the marathon framework is unreachable
 func (c *Client) Ping() error {
-	return c.apiCall("GET", "/ping", nil, nil)
+	return c.PingContext(context.Background())
 }
 
-// URI returns the marathon uri
+// PingContext returns an error if the marathon framework is unreachable
+func (c *Client) PingContext(ctx context.Context) error {
+	return c.apiCall(ctx, "GET", "/ping", nil, nil)
+}
+
+// Leader returns the address of the current marathon leader (format:
+// host:port). The next requests are sent to the leader first.
+func (c *Client) Leader() (string, error) {
+	return c.LeaderContext(context.Background())
+}
+
+// LeaderContext returns the address of the current marathon leader
+// (format: host:port). The next requests are sent to the leader first.
+func (c *Client) LeaderContext(ctx context.Context) (string, error) {
+	var result struct {
+		Leader string `json:"leader"`
+	}
+
+	if err := c.apiCall(ctx, "GET", "/v2/leader", nil, &result); err != nil {
+		return "", err
+	}
+
+	c.cluster.setLeader(result.Leader)
+
+	return result.Leader, nil
+}
+
+// httpClient returns the configured http client
+func (c *Client) httpClient() *http.Client {
+	if c.config.HTTPClient != nil {
+		return c.config.HTTPClient
+	}
+
+	return http.DefaultClient
+}
+
+// URI returns the uri of the marathon instance preferred for the next
+// request
 func (c *Client) URI() string {
-	return c.config.URI
+	endpoints := c.cluster.endpoints()
+	if len(endpoints) == 0 {
+		return ""
+	}
+
+	return endpoints[0]
 }
@@ -0,0 +1,235 @@
+package marathon
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func newHangingServer() (*httptest.Server, chan struct{}) {
+	release := make(chan struct{})
+
+	ts := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, rq *http.Request) {
+		select {
+		case <-rq.Context().Done():
+		case <-release:
+		}
+	}))
+
+	return ts, release
+}
+
+func TestApplicationsContextWithErrorOnCancel(t *testing.T) {
+	assert := assert.New(t)
+
+	ts, release := newHangingServer()
+	defer ts.Close()
+	defer close(release)
+
+	client := NewClient(&Config{
+		URI: ts.URL,
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+
+	apps, err := client.ApplicationsContext(ctx, "service-test")
+	assert.Error(err, "an error was expected in applications")
+
+	assert.Nil(apps, "the applications should be nil")
+	assert.True(time.Since(start) < time.Second, "the request should be canceled")
+}
+
+func TestClientWithErrorOnRequestTimeout(t *testing.T) {
+	assert := assert.New(t)
+
+	ts, release := newHangingServer()
+	defer ts.Close()
+	defer close(release)
+
+	client := NewClient(&Config{
+		URI:            ts.URL,
+		RequestTimeout: 100 * time.Millisecond,
+	})
+
+	start := time.Now()
+
+	tasks, err := client.Tasks("/test")
+	assert.Error(err, "an error was expected in tasks")
+
+	assert.Nil(tasks, "the tasks should be nil")
+	assert.True(time.Since(start) < time.Second, "the request should time out")
+}
+
+func TestClientFailoverOnRequestTimeout(t *testing.T) {
+	assert := assert.New(t)
+
+	hung, release := newHangingServer()
+	defer hung.Close()
+	defer close(release)
+
+	healthy := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, rq *http.Request) {
+		rw.Header().Set("Content-Type", "application/json")
+		_, _ = rw.Write([]byte(`[]`))
+	}))
+	defer healthy.Close()
+
+	client := NewClient(&Config{
+		URI:            hung.URL,
+		Endpoints:      []string{healthy.URL},
+		RequestTimeout: 200 * time.Millisecond,
+	})
+
+	_, err := client.Applications("service-test")
+	assert.NoError(err, "an unexpected error occured in applications")
+
+	assert.Equal(healthy.URL, client.URI(), "the hung instance should be marked down")
+
+	start := time.Now()
+
+	_, err = client.Applications("service-test")
+	assert.NoError(err, "an unexpected error occured in applications")
+
+	assert.True(time.Since(start) < 200*time.Millisecond, "the request should skip the hung instance")
+}
+
+func TestClientWithErrorOnChangeTimeout(t *testing.T) {
+	assert := assert.New(t)
+
+	hung, release := newHangingServer()
+	defer hung.Close()
+	defer close(release)
+
+	var requests int32
+
+	healthy := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, rq *http.Request) {
+		atomic.AddInt32(&requests, 1)
+
+		rw.Header().Set("Content-Type", "application/json")
+		_, _ = rw.Write([]byte(`{"deploymentId":"1","version":"1"}`))
+	}))
+	defer healthy.Close()
+
+	client := NewClient(&Config{
+		URI:            hung.URL,
+		Endpoints:      []string{healthy.URL},
+		RequestTimeout: 200 * time.Millisecond,
+	})
+
+	// The hung instance may have applied the change.
+	_, err := client.CreateApplication(&Application{ID: "/test"})
+	assert.Error(err, "an error was expected in create application")
+
+	assert.Equal(int32(0), atomic.LoadInt32(&requests), "the change shouldn't be sent again")
+	assert.Equal(healthy.URL, client.URI(), "the hung instance should be marked down")
+}
+
+func TestApplicationsWithoutError(t *testing.T) {
+	assert := assert.New(t)
+
+	tests := []struct {
+		label string
+		query string
+	}{
+		{label: "", query: ""},
+		{label: "RESOLVER_0_NAME==service-test", query: "label=RESOLVER_0_NAME%3D%3Dservice-test"},
+	}
+
+	for _, test := range tests {
+		requests := make(chan *apiRequest, 1)
+
+		ts := newAPIServer(http.StatusOK, []map[string]interface{}{{"id": "/test"}}, requests)
+
+		client := NewClient(&Config{URI: ts.URL})
+
+		apps, err := client.Applications(test.label)
+		assert.NoError(err, "an unexpected error occured in applications")
+
+		assert.Equal(1, len(apps), "the number of applications should be 1")
+
+		rq := <-requests
+		assert.Equal("/v2/apps", rq.path, "the paths should be equals")
+		assert.Equal(test.query, rq.query, "the queries should be equals")
+
+		ts.Close()
+	}
+}
+
+func TestTasksWithoutError(t *testing.T) {
+	assert := assert.New(t)
+
+	requests := make(chan *apiRequest, 1)
+
+	ts := newAPIServer(http.StatusOK, map[string]interface{}{
+		"tasks": []map[string]interface{}{
+			{
+				"id":        "group_test.1",
+				"appId":     "/group/test",
+				"host":      "10.0.0.1",
+				"ports":     []int{31000},
+				"state":     "TASK_RUNNING",
+				"startedAt": "2019-01-01T00:00:00.000Z",
+				"version":   "2019-01-01T00:00:00.000Z",
+				"slaveId":   "agent-1",
+				"ipAddresses": []map[string]interface{}{
+					{"ipAddress": "172.17.0.2", "protocol": "IPv4"},
+				},
+				"healthCheckResults": []map[string]interface{}{
+					{"alive": true, "consecutiveFailures": 0, "instanceId": "group_test.instance-1"},
+				},
+			},
+		},
+	}, requests)
+	defer ts.Close()
+
+	client := NewClient(&Config{URI: ts.URL})
+
+	tasks, err := client.Tasks("/group/test")
+	assert.NoError(err, "an unexpected error occured in tasks")
+
+	rq := <-requests
+	assert.Equal("GET", rq.method, "the methods should be equals")
+	assert.Equal("/v2/apps/group/test/tasks", rq.path, "the paths should be equals")
+
+	assert.Equal(1, len(tasks), "the number of tasks should be 1")
+
+	task := tasks[0]
+	assert.Equal("group_test.1", task.ID, "the task ids should be equals")
+	assert.Equal(TaskRunning, task.State, "the task states should be equals")
+	assert.Equal("2019-01-01T00:00:00.000Z", task.Version, "the versions should be equals")
+	assert.Equal("agent-1", task.SlaveID, "the slave ids should be equals")
+	assert.NotNil(task.StartedAt, "the start time should be set")
+	assert.Equal([]IPAddress{{IPAddress: "172.17.0.2", Protocol: "IPv4"}}, task.IPAddresses, "the ip addresses should be equals")
+	assert.Equal(1, len(task.HealthCheckResults), "the number of health check results should be 1")
+	assert.True(task.HealthCheckResults[0].Alive, "the health check result should be alive")
+	assert.Equal("group_test.instance-1", task.HealthCheckResults[0].InstanceID, "the instance ids should be equals")
+}
+
+func TestTaskAlive(t *testing.T) {
+	assert := assert.New(t)
+
+	tests := []struct {
+		name         string
+		task         *Task
+		healthChecks int
+		alive        bool
+	}{
+		{"running", &Task{State: TaskRunning}, 0, true},
+		{"without state", &Task{}, 0, true},
+		{"staging", &Task{State: "TASK_STAGING"}, 0, false},
+		{"without health check result", &Task{State: TaskRunning}, 1, false},
+		{"healthy", &Task{State: TaskRunning, HealthCheckResults: []HealthCheckResult{{Alive: true}}}, 1, true},
+		{"unhealthy", &Task{State: TaskRunning, HealthCheckResults: []HealthCheckResult{{Alive: false}}}, 1, false},
+	}
+
+	for _, test := range tests {
+		assert.Equal(test.alive, test.task.Alive(test.healthChecks), "the liveness should be equals: "+test.name)
+	}
+}
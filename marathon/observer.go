@@ -0,0 +1,63 @@
+package marathon
+
+import (
+	"strings"
+	"time"
+)
+
+// Observer is notified of each request sent to marathon and to the mesos
+// master (e.g. to record metrics)
+type Observer interface {
+	// ObserveRequest reports a request given its method, its endpoint
+	// (the path with its ids replaced, e.g. /v2/apps/{id}/tasks), the
+	// response status code or 0 if no response was received and the
+	// request duration until the response headers.
+	ObserveRequest(method, endpoint string, status int, duration time.Duration)
+}
+
+// observe reports a request to the configured observer
+func (c *Client) observe(method, path string, status int, start time.Time) {
+	if c.config.Observer == nil {
+		return
+	}
+
+	c.config.Observer.ObserveRequest(method, endpoint(path), status, time.Since(start))
+}
+
+// endpoint returns the path of a request without its query and with its
+// ids replaced, the application and group ids containing slashes
+func endpoint(path string) string {
+	if i := strings.Index(path, "?"); i >= 0 {
+		path = path[:i]
+	}
+
+	switch {
+	case strings.HasPrefix(path, "/v2/apps/"):
+		id := strings.TrimPrefix(path, "/v2/apps/")
+
+		switch {
+		case strings.HasSuffix(id, "/tasks"):
+			return "/v2/apps/{id}/tasks"
+		case strings.HasSuffix(id, "/restart"):
+			return "/v2/apps/{id}/restart"
+		case strings.HasSuffix(id, "/versions"):
+			return "/v2/apps/{id}/versions"
+		case strings.Contains(id, "/versions/"):
+			return "/v2/apps/{id}/versions/{version}"
+		}
+
+		return "/v2/apps/{id}"
+	case strings.HasPrefix(path, "/v2/pods/"):
+		if strings.HasSuffix(path, "::status") {
+			return "/v2/pods/{id}::status"
+		}
+
+		return "/v2/pods/{id}"
+	case strings.HasPrefix(path, "/v2/groups/"):
+		return "/v2/groups/{id}"
+	case strings.HasPrefix(path, "/v2/deployments/"):
+		return "/v2/deployments/{id}"
+	}
+
+	return path
+}
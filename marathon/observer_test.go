@@ -0,0 +1,81 @@
+package marathon
+
+import (
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type request struct {
+	method   string
+	endpoint string
+	status   int
+}
+
+type observer struct {
+	mu       sync.Mutex
+	requests []request
+}
+
+func (o *observer) ObserveRequest(method, endpoint string, status int, duration time.Duration) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	o.requests = append(o.requests, request{method: method, endpoint: endpoint, status: status})
+}
+
+func TestEndpoint(t *testing.T) {
+	assert := assert.New(t)
+
+	tests := map[string]string{
+		"/v2/apps?label=RESOLVER_0_NAME":               "/v2/apps",
+		"/v2/apps/prod/payments/api":                   "/v2/apps/{id}",
+		"/v2/apps/prod/payments/api?embed=app.tasks":   "/v2/apps/{id}",
+		"/v2/apps/prod/payments/api/tasks":             "/v2/apps/{id}/tasks",
+		"/v2/apps/test/restart?force=true":             "/v2/apps/{id}/restart",
+		"/v2/apps/test/versions":                       "/v2/apps/{id}/versions",
+		"/v2/apps/test/versions/2019-01-01T00:00:00Z":  "/v2/apps/{id}/versions/{version}",
+		"/v2/pods/prod/api::status":                    "/v2/pods/{id}::status",
+		"/v2/pods/prod/api":                            "/v2/pods/{id}",
+		"/v2/groups/prod":                              "/v2/groups/{id}",
+		"/v2/deployments/97c136bf-5a28-4821-9d94-4804": "/v2/deployments/{id}",
+		"/slaves?slave_id=agent-1":                     "/slaves",
+		"/ping":                                        "/ping",
+	}
+
+	for path, expected := range tests {
+		assert.Equal(expected, endpoint(path), "the endpoints should be equals")
+	}
+}
+
+func TestClientWithObserver(t *testing.T) {
+	assert := assert.New(t)
+
+	requests := make(chan *apiRequest, 1)
+
+	ts := newAPIServer(http.StatusNotFound, map[string]string{"message": "not found"}, requests)
+	defer ts.Close()
+
+	o := &observer{}
+
+	client := NewClient(&Config{URI: ts.URL, Observer: o})
+
+	_, err := client.Tasks("/prod/api")
+	assert.True(IsNotFound(err), "a not found error was expected in tasks")
+
+	client = NewClient(&Config{URI: "http://127.0.0.1:1", Observer: o})
+
+	err = client.Ping()
+	assert.Error(err, "an error was expected in ping")
+
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	assert.Equal([]request{
+		{method: "GET", endpoint: "/v2/apps/{id}/tasks", status: http.StatusNotFound},
+		{method: "GET", endpoint: "/ping", status: 0},
+	}, o.requests, "the requests should be equals")
+}
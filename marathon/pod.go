@@ -0,0 +1,261 @@
+package marathon
+
+import (
+	"context"
+	"io"
+	"strings"
+)
+
+// Pod instance states reported by marathon
+const (
+	PodInstancePending  = "PENDING"
+	PodInstanceStaging  = "STAGING"
+	PodInstanceStable   = "STABLE"
+	PodInstanceDegraded = "DEGRADED"
+	PodInstanceTerminal = "TERMINAL"
+)
+
+// deploymentIDHeader is the header carrying the deployment id in the
+// responses to the pods changes
+const deploymentIDHeader = "Marathon-Deployment-Id"
+
+// Pod represents the object for a pod in marathon
+type Pod struct {
+	ID          string             `json:"id"`
+	Labels      *map[string]string `json:"labels,omitempty"`
+	User        string             `json:"user,omitempty"`
+	Environment map[string]string  `json:"environment,omitempty"`
+	Containers  []*PodContainer    `json:"containers"`
+	Networks    []*PodNetwork      `json:"networks,omitempty"`
+	Scaling     *PodScaling        `json:"scaling,omitempty"`
+	Version     string             `json:"version,omitempty"`
+}
+
+// PodContainer is the definition of a container of a pod
+type PodContainer struct {
+	Name        string             `json:"name"`
+	Exec        *PodExec           `json:"exec,omitempty"`
+	Image       *PodImage          `json:"image,omitempty"`
+	Resources   *PodResources      `json:"resources,omitempty"`
+	Endpoints   []*PodEndpoint     `json:"endpoints,omitempty"`
+	HealthCheck *PodHealthCheck    `json:"healthCheck,omitempty"`
+	Labels      *map[string]string `json:"labels,omitempty"`
+}
+
+// PodExec is the command executed by a pod container
+type PodExec struct {
+	Command struct {
+		Shell string `json:"shell,omitempty"`
+	} `json:"command"`
+}
+
+// PodImage is the image of a pod container
+type PodImage struct {
+	Kind string `json:"kind"`
+	ID   string `json:"id"`
+}
+
+// PodResources are the resources allocated to a pod container
+type PodResources struct {
+	CPUs float64 `json:"cpus"`
+	Mem  float64 `json:"mem"`
+	Disk float64 `json:"disk,omitempty"`
+}
+
+// PodEndpoint is a named port exposed by a pod container. The container
+// port is set with container networking and the host port with host
+// networking (0 for a port allocated by mesos).
+type PodEndpoint struct {
+	Name          string             `json:"name"`
+	ContainerPort int                `json:"containerPort,omitempty"`
+	HostPort      int                `json:"hostPort"`
+	Protocol      []string           `json:"protocol,omitempty"`
+	Labels        *map[string]string `json:"labels,omitempty"`
+}
+
+// PodHealthCheck is the health check of a pod container
+type PodHealthCheck struct {
+	HTTP *struct {
+		Endpoint string `json:"endpoint"`
+		Path     string `json:"path,omitempty"`
+		Scheme   string `json:"scheme,omitempty"`
+	} `json:"http,omitempty"`
+	TCP *struct {
+		Endpoint string `json:"endpoint"`
+	} `json:"tcp,omitempty"`
+	GracePeriodSeconds     int `json:"gracePeriodSeconds,omitempty"`
+	IntervalSeconds        int `json:"intervalSeconds,omitempty"`
+	MaxConsecutiveFailures int `json:"maxConsecutiveFailures,omitempty"`
+	TimeoutSeconds         int `json:"timeoutSeconds,omitempty"`
+}
+
+// PodNetwork is a network joined by a pod (mode: host or container)
+type PodNetwork struct {
+	Name string `json:"name,omitempty"`
+	Mode string `json:"mode"`
+}
+
+// PodScaling is the scaling policy of a pod
+type PodScaling struct {
+	Kind      string `json:"kind"`
+	Instances int    `json:"instances"`
+}
+
+// PodStatus is the status of a pod and its instances
+type PodStatus struct {
+	ID        string         `json:"id"`
+	Spec      *Pod           `json:"spec,omitempty"`
+	Status    string         `json:"status"`
+	Instances []*PodInstance `json:"instances,omitempty"`
+}
+
+// PodInstance is an instance of a pod running on a mesos agent
+type PodInstance struct {
+	ID            string                `json:"id"`
+	Status        string                `json:"status"`
+	AgentHostname string                `json:"agentHostname"`
+	AgentID       string                `json:"agentId,omitempty"`
+	Networks      []*PodNetworkStatus   `json:"networks,omitempty"`
+	Containers    []*PodContainerStatus `json:"containers,omitempty"`
+	SpecReference string                `json:"specReference,omitempty"`
+}
+
+// PodNetworkStatus lists the addresses of a pod instance on a network
+type PodNetworkStatus struct {
+	Name      string   `json:"name,omitempty"`
+	Addresses []string `json:"addresses"`
+}
+
+// PodContainerStatus is the status of a pod instance container
+type PodContainerStatus struct {
+	Name        string               `json:"name"`
+	Status      string               `json:"status"`
+	ContainerID string               `json:"containerId,omitempty"`
+	Endpoints   []*PodEndpointStatus `json:"endpoints,omitempty"`
+}
+
+// PodEndpointStatus is the status of a pod instance endpoint. Healthy is
+// nil if the endpoint has no health check.
+type PodEndpointStatus struct {
+	Name              string `json:"name"`
+	AllocatedHostPort int    `json:"allocatedHostPort,omitempty"`
+	Healthy           *bool  `json:"healthy,omitempty"`
+}
+
+// Pods returns every pods
+func (c *Client) Pods() ([]*Pod, error) {
+	return c.PodsContext(context.Background())
+}
+
+// PodsContext returns every pods
+func (c *Client) PodsContext(ctx context.Context) ([]*Pod, error) {
+	pods := []*Pod{}
+
+	if err := c.apiCall(ctx, "GET", "/v2/pods", nil, &pods); err != nil {
+		return nil, err
+	}
+
+	return pods, nil
+}
+
+// Pod returns a pod given its id
+func (c *Client) Pod(id string) (*Pod, error) {
+	return c.PodContext(context.Background(), id)
+}
+
+// PodContext returns a pod given its id
+func (c *Client) PodContext(ctx context.Context, id string) (*Pod, error) {
+	pod := &Pod{}
+
+	if err := c.apiCall(ctx, "GET", podPath(id), nil, pod); err != nil {
+		return nil, err
+	}
+
+	return pod, nil
+}
+
+// PodStatus returns the status of a pod and its instances
+func (c *Client) PodStatus(id string) (*PodStatus, error) {
+	return c.PodStatusContext(context.Background(), id)
+}
+
+// PodStatusContext returns the status of a pod and its instances
+func (c *Client) PodStatusContext(ctx context.Context, id string) (*PodStatus, error) {
+	status := &PodStatus{}
+
+	if err := c.apiCall(ctx, "GET", podPath(id)+"::status", nil, status); err != nil {
+		return nil, err
+	}
+
+	return status, nil
+}
+
+// CreatePod creates a pod and returns the deployment starting its
+// instances
+func (c *Client) CreatePod(pod *Pod) (*DeploymentID, error) {
+	return c.CreatePodContext(context.Background(), pod)
+}
+
+// CreatePodContext creates a pod and returns the deployment starting its
+// instances
+func (c *Client) CreatePodContext(ctx context.Context, pod *Pod) (*DeploymentID, error) {
+	return c.changePod(ctx, "POST", "/v2/pods", pod)
+}
+
+// UpdatePod replaces a pod definition. The force flag overrides the
+// deployment in progress which locks the pod.
+func (c *Client) UpdatePod(pod *Pod, force bool) (*DeploymentID, error) {
+	return c.UpdatePodContext(context.Background(), pod, force)
+}
+
+// UpdatePodContext replaces a pod definition. The force flag overrides
+// the deployment in progress which locks the pod.
+func (c *Client) UpdatePodContext(ctx context.Context, pod *Pod, force bool) (*DeploymentID, error) {
+	return c.changePod(ctx, "PUT", withParams(podPath(pod.ID), forceParams(force)), pod)
+}
+
+// DeletePod destroys a pod and its instances
+func (c *Client) DeletePod(id string, force bool) (*DeploymentID, error) {
+	return c.DeletePodContext(context.Background(), id, force)
+}
+
+// DeletePodContext destroys a pod and its instances
+func (c *Client) DeletePodContext(ctx context.Context, id string, force bool) (*DeploymentID, error) {
+	return c.changePod(ctx, "DELETE", withParams(podPath(id), forceParams(force)), nil)
+}
+
+// changePod sends a pod change and returns the deployment applying it.
+// Marathon returns the deployment id in a header for the pods.
+func (c *Client) changePod(ctx context.Context, method, path string, pod *Pod) (*DeploymentID, error) {
+	var body io.Reader
+	var result interface{}
+
+	version := &struct {
+		Version string `json:"version"`
+	}{}
+
+	if pod != nil {
+		var err error
+		if body, err = encode(pod); err != nil {
+			return nil, err
+		}
+		// The pod is returned only
+		// when it is created or updated.
+		result = version
+	}
+
+	header, err := c.apiCallHeader(ctx, method, path, body, result)
+	if err != nil {
+		return nil, err
+	}
+
+	return &DeploymentID{
+		DeploymentID: header.Get(deploymentIDHeader),
+		Version:      version.Version,
+	}, nil
+}
+
+// podPath returns the api path of a pod given its id
+func podPath(id string) string {
+	return "/v2/pods/" + strings.TrimPrefix(id, "/")
+}
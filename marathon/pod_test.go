@@ -0,0 +1,120 @@
+package marathon
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/unrolled/render"
+)
+
+func TestPodStatusWithoutError(t *testing.T) {
+	assert := assert.New(t)
+
+	requests := make(chan *apiRequest, 1)
+
+	ts := newAPIServer(http.StatusOK, map[string]interface{}{
+		"id":     "/web",
+		"status": "STABLE",
+		"instances": []map[string]interface{}{
+			{
+				"id":            "web.instance-1",
+				"status":        "STABLE",
+				"agentHostname": "10.0.0.1",
+				"containers": []map[string]interface{}{
+					{
+						"name":   "server",
+						"status": "TASK_RUNNING",
+						"endpoints": []map[string]interface{}{
+							{"name": "grpc", "allocatedHostPort": 31000, "healthy": true},
+						},
+					},
+				},
+			},
+		},
+	}, requests)
+	defer ts.Close()
+
+	client := NewClient(&Config{URI: ts.URL})
+
+	status, err := client.PodStatus("/web")
+	assert.NoError(err, "an unexpected error occured in pod status")
+
+	rq := <-requests
+	assert.Equal("/v2/pods/web::status", rq.path, "the paths should be equals")
+
+	assert.Equal(1, len(status.Instances), "the number of instances should be 1")
+
+	endpoint := status.Instances[0].Containers[0].Endpoints[0]
+	assert.Equal(31000, endpoint.AllocatedHostPort, "the ports should be equals")
+	assert.True(*endpoint.Healthy, "the endpoint should be healthy")
+}
+
+func TestPodChangesWithoutError(t *testing.T) {
+	assert := assert.New(t)
+
+	requests := make(chan *apiRequest, 1)
+
+	handler := http.HandlerFunc(func(rw http.ResponseWriter, rq *http.Request) {
+		body, _ := ioutil.ReadAll(rq.Body)
+
+		requests <- &apiRequest{
+			method: rq.Method,
+			path:   rq.URL.Path,
+			query:  rq.URL.RawQuery,
+			body:   body,
+		}
+
+		rw.Header().Set("Marathon-Deployment-Id", "deployment-1")
+
+		if rq.Method == "DELETE" {
+			rw.WriteHeader(http.StatusAccepted)
+			return
+		}
+
+		render.New().JSON(rw, http.StatusOK, &Pod{ID: "/web", Version: "2019-01-01T00:00:00.000Z"})
+	})
+
+	ts := httptest.NewServer(handler)
+	defer ts.Close()
+
+	client := NewClient(&Config{URI: ts.URL})
+
+	pod := &Pod{
+		ID: "/web",
+		Containers: []*PodContainer{
+			{
+				Name:      "server",
+				Endpoints: []*PodEndpoint{{Name: "grpc", HostPort: 0}},
+			},
+		},
+	}
+
+	tests := []struct {
+		name    string
+		call    func() (*DeploymentID, error)
+		method  string
+		path    string
+		query   string
+		version string
+	}{
+		{"creation", func() (*DeploymentID, error) { return client.CreatePod(pod) }, "POST", "/v2/pods", "", "2019-01-01T00:00:00.000Z"},
+		{"update", func() (*DeploymentID, error) { return client.UpdatePod(pod, true) }, "PUT", "/v2/pods/web", "force=true", "2019-01-01T00:00:00.000Z"},
+		{"deletion", func() (*DeploymentID, error) { return client.DeletePod("/web", false) }, "DELETE", "/v2/pods/web", "", ""},
+	}
+
+	for _, test := range tests {
+		deployment, err := test.call()
+		assert.NoError(err, "an unexpected error occured in pod "+test.name)
+
+		rq := <-requests
+		assert.Equal(test.method, rq.method, "the methods should be equals")
+		assert.Equal(test.path, rq.path, "the paths should be equals")
+		assert.Equal(test.query, rq.query, "the queries should be equals")
+
+		assert.Equal("deployment-1", deployment.DeploymentID, "the deployment ids should be equals")
+		assert.Equal(test.version, deployment.Version, "the versions should be equals")
+	}
+}
@@ -1,56 +1,237 @@
 package marathon
 
 import (
+	"bytes"
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"io/ioutil"
 	"net/http"
+	"net/url"
+	"strings"
+	"time"
 )
 
-func (c *Client) apiCall(method, path string, reader io.Reader, result interface{}) error {
-	req, err := c.makeRequest(method, path, reader)
+// encode returns the json encoding of a request body
+func encode(v interface{}) (io.Reader, error) {
+	b, err := json.Marshal(v)
 	if err != nil {
-		return err
+		return nil, err
+	}
+
+	return bytes.NewReader(b), nil
+}
+
+func (c *Client) apiCall(ctx context.Context, method, path string, reader io.Reader, result interface{}) error {
+	_, err := c.apiCallHeader(ctx, method, path, reader, result)
+	return err
+}
+
+// apiCallHeader sends an api call and returns the response headers (e.g.
+// the deployment id of the pods changes)
+func (c *Client) apiCallHeader(ctx context.Context, method, path string, reader io.Reader, result interface{}) (http.Header, error) {
+	var body []byte
+	if reader != nil {
+		b, err := ioutil.ReadAll(reader)
+		if err != nil {
+			return nil, err
+		}
+		body = b
 	}
 
-	resp, err := http.DefaultClient.Do(req)
+	resp, err := c.do(ctx, method, path, body, nil, c.config.RequestTimeout, false)
 	if err != nil {
-		return err
+		return nil, err
 	}
+	defer resp.Body.Close()
 
 	if resp.StatusCode >= 400 {
-		return parseError(resp)
+		return nil, parseError(resp)
 	}
 
 	if result != nil {
 		b, err := ioutil.ReadAll(resp.Body)
 		if err != nil {
-			return err
+			return nil, err
 		}
 
 		if err := json.Unmarshal(b, result); err != nil {
-			return err
+			return nil, err
+		}
+	}
+
+	return resp.Header, nil
+}
+
+// do sends a request to the marathon instances. The request rotates to
+// the next instance on connection errors, timeouts and 5xx responses, the
+// failing instance being marked down. A change timing out isn't sent
+// again though, the instance having possibly applied it. The timeout, if
+// any, is allowed to each instance until the response body is closed or,
+// for a stream, until the response headers are received.
+func (c *Client) do(ctx context.Context, method, path string, body []byte, header http.Header, timeout time.Duration, stream bool) (*http.Response, error) {
+	if c.cluster.leaderStale() {
+		go func() {
+			ctx, cancel := context.WithTimeout(context.Background(), leaderRefreshInterval)
+			defer cancel()
+
+			_, _ = c.LeaderContext(ctx)
+		}()
+	}
+
+	endpoints := c.cluster.endpoints()
+	if len(endpoints) == 0 {
+		return nil, errors.New("no marathon instance configured")
+	}
+
+	var err error
+
+	for i, uri := range endpoints {
+		var resp *http.Response
+
+		var attempt context.Context
+		var cancel context.CancelFunc
+
+		if timeout > 0 && !stream {
+			attempt, cancel = context.WithTimeout(ctx, timeout)
+		} else {
+			attempt, cancel = context.WithCancel(ctx)
+		}
+
+		// A stream is given the timeout
+		// until it is open only.
+		var timer *time.Timer
+		if timeout > 0 && stream {
+			timer = time.AfterFunc(timeout, cancel)
+		}
+
+		resp, err = c.send(attempt, uri, method, path, body, header)
+
+		if timer != nil {
+			timer.Stop()
+		}
+
+		if err != nil {
+			cancel()
+
+			if ctx.Err() != nil {
+				return nil, err
+			}
+
+			// A hung instance times out
+			// like an unreachable one.
+			if attempt.Err() != nil {
+				c.cluster.markDown(uri)
+
+				if !idempotent(method) {
+					return nil, err
+				}
+				continue
+			}
+
+			if _, ok := err.(*url.Error); ok {
+				c.cluster.markDown(uri)
+				continue
+			}
+			return nil, err
 		}
+
+		resp.Body = &cancelBody{ReadCloser: resp.Body, cancel: cancel}
+
+		if resp.StatusCode >= 500 {
+			c.cluster.markDown(uri)
+
+			if i < len(endpoints)-1 {
+				_ = resp.Body.Close()
+				continue
+			}
+
+			return resp, nil
+		}
+
+		c.cluster.markUp(uri)
+
+		return resp, nil
 	}
 
-	return nil
+	return nil, err
 }
 
-func (c *Client) makeRequest(method, path string, reader io.Reader) (*http.Request, error) {
-	url := fmt.Sprintf("%s%s", c.config.URI, path)
+// idempotent reports whether a request can be sent again without effect
+func idempotent(method string) bool {
+	return method == http.MethodGet || method == http.MethodHead
+}
+
+// cancelBody is a response body releasing the context of its request
+// once closed
+type cancelBody struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+func (b *cancelBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.cancel()
+	return err
+}
+
+// send sends a request to a marathon instance. When the service account
+// token is rejected, the token is discarded and the request is sent once
+// again with a new one.
+func (c *Client) send(ctx context.Context, uri, method, path string, body []byte, header http.Header) (*http.Response, error) {
+	for attempt := 0; ; attempt++ {
+		req, err := c.makeRequest(ctx, uri, method, path, bytes.NewReader(body))
+		if err != nil {
+			return nil, err
+		}
 
-	request, err := http.NewRequest(method, url, reader)
+		for k, v := range header {
+			req.Header[k] = v
+		}
+
+		start := time.Now()
+
+		resp, err := c.httpClient().Do(req)
+		if err != nil {
+			c.observe(method, path, 0, start)
+			return nil, err
+		}
+
+		c.observe(method, path, resp.StatusCode, start)
+
+		if resp.StatusCode == http.StatusUnauthorized && c.tokens != nil && attempt == 0 {
+			_ = resp.Body.Close()
+			c.tokens.invalidate(strings.TrimPrefix(req.Header.Get("Authorization"), "token="))
+			continue
+		}
+
+		return resp, nil
+	}
+}
+
+func (c *Client) makeRequest(ctx context.Context, uri, method, path string, reader io.Reader) (*http.Request, error) {
+	request, err := http.NewRequest(method, uri+path, reader)
 	if err != nil {
 		return nil, err
 	}
 
+	request = request.WithContext(ctx)
+
 	if c.config.HTTPBasicAuthUser != "" && c.config.HTTPBasicAuthPassword != "" {
 		request.SetBasicAuth(c.config.HTTPBasicAuthUser, c.config.HTTPBasicAuthPassword)
 	}
 
-	if c.config.DCOSToken != "" {
-		request.Header.Add("Authorization", "token="+c.config.HTTPBasicAuthUser)
+	if c.tokens != nil {
+		token, err := c.tokens.Token(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("couldn't log in to DC/OS: %v", err)
+		}
+
+		request.Header.Add("Authorization", "token="+token)
+	} else if c.config.DCOSToken != "" {
+		request.Header.Add("Authorization", "token="+c.config.DCOSToken)
 	}
 
 	request.Header.Add("Content-Type", "application/json")
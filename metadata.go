@@ -0,0 +1,61 @@
+package resolver
+
+import (
+	"google.golang.org/grpc/attributes"
+	grpcresolver "google.golang.org/grpc/resolver"
+)
+
+// Metadata is the information about a resolved address. It is the
+// metadata of the naming updates and an attribute of the grpc resolver
+// addresses.
+type Metadata struct {
+	// AppID is the id of the marathon application
+	// (or pod) running the address.
+	AppID string
+	// Weight is the weight of the address set by
+	// the marathon labels (default: 1).
+	Weight uint32
+	// Zone and Rack are the attributes of the mesos
+	// agent running the address, when the mesos
+	// master is configured (see WithMesos).
+	Zone string
+	Rack string
+	// Stale is true when the address comes from the
+	// snapshot of a previous run (see WithSnapshot),
+	// until marathon is reachable again.
+	Stale bool
+}
+
+// equal returns true if the metadata announce the address the same way.
+// The application id isn't compared, an address moving to another
+// application keeping its connections.
+func (m *Metadata) equal(o *Metadata) bool {
+	return m.Weight == o.Weight && m.Zone == o.Zone && m.Rack == o.Rack && m.Stale == o.Stale
+}
+
+// metadataKey is the attributes key of the address metadata
+type metadataKey struct{}
+
+// AddressMetadata returns the metadata of an address pushed by the
+// resolver (e.g. in a balancer) or nil if there is none
+func AddressMetadata(addr grpcresolver.Address) *Metadata {
+	if addr.Attributes == nil {
+		return nil
+	}
+
+	md, _ := addr.Attributes.Value(metadataKey{}).(*Metadata)
+
+	return md
+}
+
+// NewAddress returns a grpc resolver address carrying its metadata (e.g.
+// to feed a balancer in tests)
+func NewAddress(addr string, md *Metadata) grpcresolver.Address {
+	a := grpcresolver.Address{Addr: addr}
+
+	if md != nil {
+		a.Attributes = attributes.New(metadataKey{}, md)
+	}
+
+	return a
+}
@@ -0,0 +1,45 @@
+package resolver
+
+import (
+	"time"
+
+	"github.com/eddyzags/resolver/marathon"
+)
+
+// Metrics is notified of the resolver activity (e.g. to record metrics,
+// see the metrics package for a prometheus collector). The methods are
+// called concurrently by the pollers of every name.
+type Metrics interface {
+	marathon.Observer
+
+	// PollError reports a failed marathon poll of a name
+	PollError(name string)
+	// Backends reports a change of the number of addresses resolved for
+	// a name, the pollers of a name adding up. A closed poller removes
+	// its addresses.
+	Backends(name string, delta int)
+	// AddressAdded and AddressDeleted report the naming updates of a
+	// name
+	AddressAdded(name string)
+	AddressDeleted(name string)
+	// ProbeTransition reports a change of the readiness of a backend
+	ProbeTransition(name string, ready bool)
+	// ProbeFailure reports a backend probe reporting a failure or which
+	// couldn't be instantiated
+	ProbeFailure(name string)
+	// Panic reports a name entering or leaving the panic mode (see
+	// WithPanicThreshold)
+	Panic(name string, inPanic bool)
+}
+
+// nopMetrics is the default metrics, ignoring the resolver activity
+type nopMetrics struct{}
+
+func (nopMetrics) ObserveRequest(method, endpoint string, status int, duration time.Duration) {}
+func (nopMetrics) PollError(name string)                                                      {}
+func (nopMetrics) Backends(name string, delta int)                                            {}
+func (nopMetrics) AddressAdded(name string)                                                   {}
+func (nopMetrics) AddressDeleted(name string)                                                 {}
+func (nopMetrics) ProbeTransition(name string, ready bool)                                    {}
+func (nopMetrics) ProbeFailure(name string)                                                   {}
+func (nopMetrics) Panic(name string, inPanic bool)                                            {}
@@ -0,0 +1,163 @@
+// Package metrics records the activity of the marathon resolver in
+// prometheus metrics. The collector is passed to the resolver and
+// registered in a prometheus registry:
+//
+//	collector := metrics.NewCollector()
+//	prometheus.MustRegister(collector)
+//
+//	r, err := resolver.New("marathon.mesos:8080", resolver.WithMetrics(collector))
+package metrics
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/eddyzags/resolver"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// namespace is the namespace of the metrics
+const namespace = "marathon_resolver"
+
+// Collector is a prometheus collector recording the resolver activity
+type Collector struct {
+	requests         *prometheus.CounterVec
+	latencies        *prometheus.HistogramVec
+	pollErrors       *prometheus.CounterVec
+	backends         *prometheus.GaugeVec
+	updates          *prometheus.CounterVec
+	probeTransitions *prometheus.CounterVec
+	probeFailures    *prometheus.CounterVec
+	panics           *prometheus.GaugeVec
+}
+
+var _ resolver.Metrics = (*Collector)(nil)
+
+// NewCollector instantiates a new collector
+func NewCollector() *Collector {
+	return &Collector{
+		requests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "marathon_requests_total",
+			Help:      "Number of requests sent to marathon and to the mesos master, by endpoint and status (0 without response).",
+		}, []string{"method", "endpoint", "status"}),
+		latencies: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "marathon_request_duration_seconds",
+			Help:      "Duration of the requests sent to marathon and to the mesos master until the response headers.",
+			Buckets:   prometheus.DefBuckets,
+		}, []string{"method", "endpoint", "status"}),
+		pollErrors: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "poll_errors_total",
+			Help:      "Number of failed marathon polls by name.",
+		}, []string{"name"}),
+		backends: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "backends",
+			Help:      "Number of addresses resolved by name.",
+		}, []string{"name"}),
+		updates: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "updates_total",
+			Help:      "Number of addresses added and deleted by name.",
+		}, []string{"name", "op"}),
+		probeTransitions: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "probe_transitions_total",
+			Help:      "Number of backend readiness changes by name and new state.",
+		}, []string{"name", "state"}),
+		probeFailures: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "probe_failures_total",
+			Help:      "Number of backend probes reporting a failure or which couldn't be instantiated by name.",
+		}, []string{"name"}),
+		panics: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "panic",
+			Help:      "Number of pollers in panic mode by name.",
+		}, []string{"name"}),
+	}
+}
+
+// collectors returns the metrics of the collector
+func (c *Collector) collectors() []prometheus.Collector {
+	return []prometheus.Collector{
+		c.requests,
+		c.latencies,
+		c.pollErrors,
+		c.backends,
+		c.updates,
+		c.probeTransitions,
+		c.probeFailures,
+		c.panics,
+	}
+}
+
+// Describe implements prometheus.Collector
+func (c *Collector) Describe(ch chan<- *prometheus.Desc) {
+	for _, collector := range c.collectors() {
+		collector.Describe(ch)
+	}
+}
+
+// Collect implements prometheus.Collector
+func (c *Collector) Collect(ch chan<- prometheus.Metric) {
+	for _, collector := range c.collectors() {
+		collector.Collect(ch)
+	}
+}
+
+// ObserveRequest implements resolver.Metrics
+func (c *Collector) ObserveRequest(method, endpoint string, status int, duration time.Duration) {
+	code := strconv.Itoa(status)
+
+	c.requests.WithLabelValues(method, endpoint, code).Inc()
+	c.latencies.WithLabelValues(method, endpoint, code).Observe(duration.Seconds())
+}
+
+// PollError implements resolver.Metrics
+func (c *Collector) PollError(name string) {
+	c.pollErrors.WithLabelValues(name).Inc()
+}
+
+// Backends implements resolver.Metrics
+func (c *Collector) Backends(name string, delta int) {
+	c.backends.WithLabelValues(name).Add(float64(delta))
+}
+
+// AddressAdded implements resolver.Metrics
+func (c *Collector) AddressAdded(name string) {
+	c.updates.WithLabelValues(name, "add").Inc()
+}
+
+// AddressDeleted implements resolver.Metrics
+func (c *Collector) AddressDeleted(name string) {
+	c.updates.WithLabelValues(name, "delete").Inc()
+}
+
+// ProbeTransition implements resolver.Metrics
+func (c *Collector) ProbeTransition(name string, ready bool) {
+	state := "not_ready"
+	if ready {
+		state = "ready"
+	}
+
+	c.probeTransitions.WithLabelValues(name, state).Inc()
+}
+
+// ProbeFailure implements resolver.Metrics
+func (c *Collector) ProbeFailure(name string) {
+	c.probeFailures.WithLabelValues(name).Inc()
+}
+
+// Panic implements resolver.Metrics
+func (c *Collector) Panic(name string, inPanic bool) {
+	if inPanic {
+		c.panics.WithLabelValues(name).Inc()
+		return
+	}
+
+	c.panics.WithLabelValues(name).Dec()
+}
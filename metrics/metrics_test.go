@@ -0,0 +1,55 @@
+package metrics
+
+import (
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCollectorWithoutError(t *testing.T) {
+	assert := assert.New(t)
+
+	c := NewCollector()
+
+	registry := prometheus.NewRegistry()
+	assert.NoError(registry.Register(c), "an unexpected error occured in collector registration")
+
+	c.ObserveRequest("GET", "/v2/apps/{id}/tasks", 200, 10*time.Millisecond)
+	c.ObserveRequest("GET", "/v2/apps/{id}/tasks", 200, 20*time.Millisecond)
+	c.ObserveRequest("GET", "/ping", 0, time.Second)
+	c.PollError("service-test")
+	c.Backends("service-test", 3)
+	c.AddressAdded("service-test")
+	c.AddressDeleted("service-test")
+	c.ProbeTransition("service-test", false)
+	c.ProbeFailure("service-test")
+	c.Panic("service-test", true)
+
+	assert.Equal(2.0, testutil.ToFloat64(c.requests.WithLabelValues("GET", "/v2/apps/{id}/tasks", "200")), "the numbers of requests should be equals")
+	assert.Equal(1.0, testutil.ToFloat64(c.requests.WithLabelValues("GET", "/ping", "0")), "the numbers of requests should be equals")
+	assert.Equal(1.0, testutil.ToFloat64(c.pollErrors.WithLabelValues("service-test")), "the numbers of poll errors should be equals")
+	assert.Equal(3.0, testutil.ToFloat64(c.backends.WithLabelValues("service-test")), "the numbers of backends should be equals")
+	assert.Equal(1.0, testutil.ToFloat64(c.updates.WithLabelValues("service-test", "add")), "the numbers of adds should be equals")
+	assert.Equal(1.0, testutil.ToFloat64(c.updates.WithLabelValues("service-test", "delete")), "the numbers of deletes should be equals")
+	assert.Equal(1.0, testutil.ToFloat64(c.probeTransitions.WithLabelValues("service-test", "not_ready")), "the numbers of transitions should be equals")
+	assert.Equal(1.0, testutil.ToFloat64(c.probeFailures.WithLabelValues("service-test")), "the numbers of probe failures should be equals")
+	assert.Equal(1.0, testutil.ToFloat64(c.panics.WithLabelValues("service-test")), "the numbers of pollers in panic should be equals")
+
+	families, err := registry.Gather()
+	assert.NoError(err, "an unexpected error occured in gathering")
+
+	assert.Equal(8, len(families), "the number of metric families should be 8")
+
+	c.Panic("service-test", false)
+
+	assert.Equal(0.0, testutil.ToFloat64(c.panics.WithLabelValues("service-test")), "the numbers of pollers in panic should be equals")
+
+	// The pollers of a name add up.
+	c.Backends("service-test", 2)
+	c.Backends("service-test", -3)
+
+	assert.Equal(2.0, testutil.ToFloat64(c.backends.WithLabelValues("service-test")), "the numbers of backends should be equals")
+}
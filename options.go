@@ -0,0 +1,330 @@
+package resolver
+
+import (
+	"log"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/eddyzags/resolver/marathon"
+
+	"google.golang.org/grpc"
+)
+
+const (
+	// defaultRequestTimeout is the default time allowed to a marathon api call
+	defaultRequestTimeout = 10 * time.Second
+
+	// defaultZoneAttribute and defaultRackAttribute are the default
+	// mesos agent attributes locating the backends
+	defaultZoneAttribute = "zone"
+	defaultRackAttribute = "rack"
+)
+
+// Option configures the resolver
+type Option func(*options)
+
+// Logger is the interface used by the resolver to report errors
+type Logger interface {
+	Printf(format string, v ...interface{})
+}
+
+type options struct {
+	healthChecks   bool
+	grpcHealth     bool
+	healthServices map[string]string
+	prober         Prober
+	probeTimeout   time.Duration
+	dialOptions    []grpc.DialOption
+	pollInterval   time.Duration
+	pollJitter     time.Duration
+	resyncInterval time.Duration
+	discovery      time.Duration
+	logger         Logger
+	httpClient     *http.Client
+	basicAuthUser  string
+	basicAuthPass  string
+	dcosToken      string
+	serviceAccount *marathon.ServiceAccount
+	requestTimeout time.Duration
+	mesosURI       string
+	zoneAttribute  string
+	rackAttribute  string
+	zone           string
+	// localityThreshold is the ratio of the local
+	// backends which must be members to route to
+	// the local zone only.
+	localityThreshold float64
+	panicThreshold    float64
+	snapshot          *snapshot
+	static            *static
+	metrics           Metrics
+	// stream is the event stream shared by
+	// the pollers of a resolver.
+	stream *stream
+}
+
+func newOptions(opts ...Option) *options {
+	o := &options{
+		probeTimeout:   defaultProbeTimeout,
+		pollInterval:   pollInterval,
+		resyncInterval: fallbackPollInterval,
+		discovery:      discoveryInterval,
+		requestTimeout: defaultRequestTimeout,
+		zoneAttribute:  defaultZoneAttribute,
+		rackAttribute:  defaultRackAttribute,
+		metrics:        nopMetrics{},
+		logger:         log.New(os.Stderr, "resolver: ", log.LstdFlags),
+	}
+
+	for _, opt := range opts {
+		opt(o)
+	}
+
+	if o.prober == nil {
+		if o.grpcHealth {
+			o.prober = &GRPCHealthProber{
+				Services:    o.healthServices,
+				Timeout:     o.probeTimeout,
+				DialOptions: o.dialOptions,
+			}
+		} else {
+			o.prober = &GRPCProber{
+				Timeout:     o.probeTimeout,
+				DialOptions: o.dialOptions,
+			}
+		}
+	}
+
+	return o
+}
+
+// marathonConfig returns the marathon client configuration given the
+// marathon uris separated by commas
+func (o *options) marathonConfig(addr string) *marathon.Config {
+	uris := strings.Split(addr, ",")
+
+	return &marathon.Config{
+		URI:                   uris[0],
+		Endpoints:             uris[1:],
+		HTTPClient:            o.httpClient,
+		HTTPBasicAuthUser:     o.basicAuthUser,
+		HTTPBasicAuthPassword: o.basicAuthPass,
+		DCOSToken:             o.dcosToken,
+		ServiceAccount:        o.serviceAccount,
+		RequestTimeout:        o.requestTimeout,
+		MesosURI:              o.mesosURI,
+		Observer:              o.metrics,
+	}
+}
+
+// WithMarathonHealthChecks gates the backends on the marathon health
+// checks results. A task is added once all its health checks are alive
+// and removed as soon as one of them fails.
+func WithMarathonHealthChecks() Option {
+	return func(o *options) {
+		o.healthChecks = true
+	}
+}
+
+// WithGRPCHealthCheck probes the backends with the grpc health checking
+// protocol and keeps only the SERVING ones. The services map associates a
+// resolved name to the health service name to check. Names missing from
+// the map check the overall server health. Servers which don't implement
+// the health service are probed on their connection state.
+func WithGRPCHealthCheck(services map[string]string) Option {
+	return func(o *options) {
+		o.grpcHealth = true
+		o.healthServices = services
+	}
+}
+
+// WithProber sets the prober monitoring the backends readiness
+// (default: GRPCProber)
+func WithProber(p Prober) Option {
+	return func(o *options) {
+		o.prober = p
+	}
+}
+
+// WithProbeTimeout sets the time allowed to the grpc probes to connect
+// to a backend (default: 5s)
+func WithProbeTimeout(timeout time.Duration) Option {
+	return func(o *options) {
+		o.probeTimeout = timeout
+	}
+}
+
+// WithProbeDialOptions sets the dial options of the grpc probes
+// connections (default: grpc.WithInsecure)
+func WithProbeDialOptions(opts ...grpc.DialOption) Option {
+	return func(o *options) {
+		o.dialOptions = opts
+	}
+}
+
+// WithPollInterval sets the interval between two marathon polls when the
+// event stream is unavailable (default: 1s). A random duration up to
+// jitter is added to each interval to spread the polls of the clients. A
+// non positive interval keeps the default.
+func WithPollInterval(interval, jitter time.Duration) Option {
+	return func(o *options) {
+		if interval > 0 {
+			o.pollInterval = interval
+		}
+		o.pollJitter = jitter
+	}
+}
+
+// WithDiscoveryInterval sets the interval between two lookups of the
+// applications labeled with the service name (default: 30s). The
+// applications are also looked up when marathon reports a change on the
+// event stream. A zero interval disables the periodic lookups.
+func WithDiscoveryInterval(interval time.Duration) Option {
+	return func(o *options) {
+		o.discovery = interval
+	}
+}
+
+// WithLogger sets the logger reporting the resolver errors (default:
+// standard logger writing on stderr)
+func WithLogger(logger Logger) Option {
+	return func(o *options) {
+		o.logger = logger
+	}
+}
+
+// WithHTTPClient sets the http client used to call marathon (default:
+// http.DefaultClient)
+func WithHTTPClient(client *http.Client) Option {
+	return func(o *options) {
+		o.httpClient = client
+	}
+}
+
+// WithRequestTimeout sets the time allowed to each marathon api call on
+// each marathon instance, the event stream excepted. A timed out instance
+// is marked down and the reads fail over, the changes failing instead
+// (default: 10s).
+func WithRequestTimeout(timeout time.Duration) Option {
+	return func(o *options) {
+		o.requestTimeout = timeout
+	}
+}
+
+// WithBasicAuth sets the marathon http basic authentication credentials
+func WithBasicAuth(user, password string) Option {
+	return func(o *options) {
+		o.basicAuthUser = user
+		o.basicAuthPass = password
+	}
+}
+
+// WithDCOSToken sets the DC/OS authentication token sent to marathon
+func WithDCOSToken(token string) Option {
+	return func(o *options) {
+		o.dcosToken = token
+	}
+}
+
+// WithDCOSServiceAccount logs in to DC/OS with a service account uid and
+// its PEM encoded private key. The authentication token is refreshed
+// automatically when it expires or is rejected.
+func WithDCOSServiceAccount(uid string, privateKey []byte) Option {
+	return func(o *options) {
+		o.serviceAccount = &marathon.ServiceAccount{
+			UID:        uid,
+			PrivateKey: privateKey,
+		}
+	}
+}
+
+// WithMesos sets the uri of the mesos master (e.g. https://leader.mesos:5050
+// or https://dcos/mesos in DC/OS). The backends are located with the
+// attributes of the mesos agents running them, the zone and the rack
+// being set in their metadata. The agents are cached.
+func WithMesos(uri string) Option {
+	return func(o *options) {
+		o.mesosURI = uri
+	}
+}
+
+// WithLocalityAttributes sets the mesos agent attributes holding the zone
+// and the rack of the backends (default: zone and rack)
+func WithLocalityAttributes(zone, rack string) Option {
+	return func(o *options) {
+		o.zoneAttribute = zone
+		o.rackAttribute = rack
+	}
+}
+
+// WithLocality routes to the backends of the client zone only. When the
+// ratio of the local backends which are ready and alive falls below the
+// threshold (e.g. 0.5), or none of them is, the backends of every zone
+// are resolved until the local zone recovers. It requires WithMesos.
+func WithLocality(zone string, threshold float64) Option {
+	return func(o *options) {
+		o.zone = zone
+		o.localityThreshold = threshold
+	}
+}
+
+// WithPanicThreshold stops removing the backends when the ratio of the
+// healthy ones falls below the threshold (e.g. 0.5): all the backends are
+// resolved, healthy or not, until enough of them recover. The backends
+// missing from marathon are left out of the ratio, a scale down removing
+// them as usual, but they are kept until the panic is over. Marathon
+// returning none of the backends (e.g. a truncated task list) is a
+// panic, unless the applications are scaled to zero, destroyed or not
+// labeled anymore. Entering and leaving the panic mode are reported to
+// the logger and the metrics (default: disabled).
+func WithPanicThreshold(threshold float64) Option {
+	return func(o *options) {
+		o.panicThreshold = threshold
+	}
+}
+
+// WithSnapshot saves the last known good address set of each name in a
+// local file, written atomically. When marathon is unreachable, the
+// resolver starts from the snapshot: the addresses are probed and their
+// metadata marked stale until marathon responds again.
+func WithSnapshot(path string) Option {
+	s := newSnapshot(path)
+
+	return func(o *options) {
+		o.snapshot = s
+	}
+}
+
+// WithStatic sets static addresses per name, resolved in place of the
+// marathon ones, along with them or when marathon returns no address
+// depending on their mode. The static addresses are probed like the
+// marathon ones.
+func WithStatic(entries map[string]StaticEntry) Option {
+	s := newStatic(entries)
+
+	return func(o *options) {
+		o.static = s
+	}
+}
+
+// WithStaticFile loads the static addresses per name from a json file
+// (see WithStatic). The file is reloaded when it is modified, checked at
+// the given interval (default: 5s).
+func WithStaticFile(path string, interval time.Duration) Option {
+	s := newStaticFile(path, interval)
+
+	return func(o *options) {
+		o.static = s
+	}
+}
+
+// WithMetrics reports the resolver activity and the marathon requests to
+// metrics (default: none). The metrics package provides a prometheus
+// collector.
+func WithMetrics(metrics Metrics) Option {
+	return func(o *options) {
+		o.metrics = metrics
+	}
+}
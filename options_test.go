@@ -0,0 +1,260 @@
+package resolver
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/eddyzags/resolver/marathon"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/unrolled/render"
+)
+
+type logger struct {
+	mu       sync.Mutex
+	messages []string
+}
+
+func (l *logger) Printf(format string, v ...interface{}) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.messages = append(l.messages, fmt.Sprintf(format, v...))
+}
+
+func (l *logger) contains(message string) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	for _, m := range l.messages {
+		if strings.Contains(m, message) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func TestOptionsDefaults(t *testing.T) {
+	assert := assert.New(t)
+
+	o := newOptions()
+
+	assert.Equal(pollInterval, o.pollInterval, "the poll intervals should be equals")
+	assert.Equal(discoveryInterval, o.discovery, "the discovery intervals should be equals")
+	assert.NotNil(o.logger, "the logger shouldn't be nil")
+
+	prober, ok := o.prober.(*GRPCProber)
+	assert.True(ok, "the default prober should be a grpc prober")
+	assert.Equal(defaultProbeTimeout, prober.Timeout, "the probe timeouts should be equals")
+}
+
+func TestOptionsPollIntervalNotPositive(t *testing.T) {
+	assert := assert.New(t)
+
+	o := newOptions(WithPollInterval(0, 0))
+	assert.Equal(pollInterval, o.pollInterval, "the poll intervals should be equals")
+
+	o = newOptions(WithPollInterval(-time.Second, 0))
+	assert.Equal(pollInterval, o.pollInterval, "the poll intervals should be equals")
+}
+
+func TestOptionsGRPCHealthCheckWithProbeTimeout(t *testing.T) {
+	assert := assert.New(t)
+
+	services := map[string]string{"service-test": "helloworld"}
+
+	o := newOptions(WithGRPCHealthCheck(services), WithProbeTimeout(time.Second))
+
+	prober, ok := o.prober.(*GRPCHealthProber)
+	assert.True(ok, "the prober should be a grpc health prober")
+	assert.Equal(time.Second, prober.Timeout, "the probe timeouts should be equals")
+	assert.Equal(services, prober.Services, "the services should be equals")
+}
+
+func TestOptionsProberOverride(t *testing.T) {
+	assert := assert.New(t)
+
+	prober := &TCPProber{}
+
+	o := newOptions(WithGRPCHealthCheck(nil), WithProber(prober))
+
+	assert.Equal(prober, o.prober, "the probers should be equals")
+}
+
+func TestResolverWithMarathonOptions(t *testing.T) {
+	assert := assert.New(t)
+
+	handler := http.HandlerFunc(func(rw http.ResponseWriter, rq *http.Request) {
+		user, password, ok := rq.BasicAuth()
+		if !ok || user != "user" || password != "password" {
+			render.New().JSON(rw, http.StatusUnauthorized, map[string]string{"message": "unauthorized"})
+			return
+		}
+
+		render.New().JSON(rw, http.StatusOK, nil)
+	})
+
+	ts := httptest.NewServer(handler)
+	defer ts.Close()
+
+	l := &logger{}
+
+	resolver, err := New(ts.URL,
+		WithHTTPClient(&http.Client{Timeout: time.Second}),
+		WithBasicAuth("user", "password"),
+		WithPollInterval(100*time.Millisecond, 10*time.Millisecond),
+		WithLogger(l),
+	)
+	assert.NoError(err, "an unexpected error occured in resolver instantiation")
+
+	assert.NotNil(resolver, "resolver shouldn't be nil")
+
+	_, err = New(ts.URL)
+	assert.Error(err, "an error was expected without credentials")
+}
+
+func TestPollWithLogger(t *testing.T) {
+	assert := assert.New(t)
+
+	apps := []*marathon.Application{
+		{
+			ID: "/test",
+			Labels: &map[string]string{
+				"RESOLVER_0_NAME": "service-test",
+			},
+		},
+	}
+
+	handler := http.HandlerFunc(func(rw http.ResponseWriter, rq *http.Request) {
+		switch rq.URL.Path {
+		case "/v2/apps":
+			render.New().JSON(rw, http.StatusOK, apps)
+		default:
+			render.New().JSON(rw, http.StatusInternalServerError, map[string]string{"message": "internal error"})
+		}
+	})
+
+	ts := httptest.NewServer(handler)
+	defer ts.Close()
+
+	l := &logger{}
+
+	marathonClient := marathon.NewClient(&marathon.Config{
+		URI: ts.URL,
+	})
+
+	poller, err := newPoll("service-test", marathonClient, WithLogger(l), WithPollInterval(50*time.Millisecond, 0))
+	assert.NoError(err, "an unexpected error occured in poller instantiation")
+
+	poller.run()
+	time.Sleep(200 * time.Millisecond)
+	poller.Close()
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	assert.True(len(l.messages) > 2, "the errors should be reported to the logger")
+}
+
+type recorder struct {
+	nopMetrics
+	mu     sync.Mutex
+	events []string
+}
+
+func (r *recorder) record(event string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.events = append(r.events, event)
+}
+
+func (r *recorder) ObserveRequest(method, endpoint string, status int, duration time.Duration) {
+	r.record(fmt.Sprintf("request %s %s %d", method, endpoint, status))
+}
+
+func (r *recorder) Backends(name string, delta int) {
+	r.record(fmt.Sprintf("backends %s %d", name, delta))
+}
+
+func (r *recorder) AddressAdded(name string) {
+	r.record("added " + name)
+}
+
+func (r *recorder) ProbeTransition(name string, ready bool) {
+	r.record(fmt.Sprintf("transition %s %t", name, ready))
+}
+
+func (r *recorder) Panic(name string, inPanic bool) {
+	r.record(fmt.Sprintf("panic %s %t", name, inPanic))
+}
+
+func (r *recorder) contains(event string) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, e := range r.events {
+		if e == event {
+			return true
+		}
+	}
+
+	return false
+}
+
+func TestPollWithMetrics(t *testing.T) {
+	assert := assert.New(t)
+
+	handler := http.HandlerFunc(func(rw http.ResponseWriter, rq *http.Request) {
+		switch rq.URL.Path {
+		case "/v2/apps":
+			render.New().JSON(rw, http.StatusOK, []*marathon.Application{
+				{ID: "/test", Labels: &map[string]string{"RESOLVER_0_NAME": "service-test"}},
+			})
+		case "/v2/apps/test/tasks":
+			render.New().JSON(rw, http.StatusOK, map[string]interface{}{
+				"tasks": []*marathon.Task{{ID: "test.1", AppID: "/test", Host: "10.0.0.1", Ports: []int{8080}}},
+			})
+		default:
+			rw.WriteHeader(http.StatusNotFound)
+		}
+	})
+
+	ts := httptest.NewServer(handler)
+	defer ts.Close()
+
+	r := &recorder{}
+	prober := newManualProber()
+
+	opts := []Option{WithMetrics(r), WithProber(prober), WithLogger(&logger{})}
+
+	marathonClient := marathon.NewClient(newOptions(opts...).marathonConfig(ts.URL))
+
+	poller, err := newPoll("service-test", marathonClient, opts...)
+	assert.NoError(err, "an unexpected error occured in poller instantiation")
+	defer poller.Close()
+
+	poller.run()
+
+	go prober.set("10.0.0.1:8080", true)
+
+	_, err = poller.Next()
+	assert.NoError(err, "an unexpected error occured in poller next")
+
+	assert.True(r.contains("request GET /v2/apps 200"), "the marathon requests should be reported")
+	assert.True(r.contains("request GET /v2/apps/{id}/tasks 200"), "the marathon requests should be reported")
+	assert.True(r.contains("transition service-test true"), "the probe transitions should be reported")
+	assert.True(r.contains("added service-test"), "the adds should be reported")
+	assert.True(r.contains("backends service-test 1"), "the backends should be reported")
+
+	poller.Close()
+
+	assert.True(r.contains("backends service-test -1"), "the backends of the closed poller should be removed")
+	assert.False(poller.ready(), "the closed poller shouldn't be ready")
+}
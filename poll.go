@@ -1,177 +1,939 @@
 package resolver
 
 import (
+	"context"
 	"errors"
-	"fmt"
-	"os"
-	"strconv"
-	"strings"
+	"math/rand"
+	"sort"
+	"sync/atomic"
 	"time"
 
 	"github.com/eddyzags/resolver/marathon"
 
-	"google.golang.org/grpc/connectivity"
 	"google.golang.org/grpc/naming"
 )
 
 type poll struct {
-	label      string
-	appID      string
-	portIndex  int64
-	probes     map[string]*Probe
-	marathon   *marathon.Client
-	updates    chan []*marathon.Task
-	unregister chan string
-	done       chan bool
-}
-
-func newPoll(label string, m *marathon.Client) (*poll, error) {
-	apps, err := m.Applications(label)
-	if err != nil {
-		return nil, err
+	label    string
+	sources  map[string]*source
+	opts     *options
+	backends map[string]*backend
+	// announced are the addresses
+	// announced to the watcher.
+	announced map[string]*Metadata
+	// resolved is the number of announced
+	// addresses, read by Resolver.Ready,
+	// -1 once the poller is closed.
+	resolved int32
+	// failover is true when the backends
+	// of the local zone aren't enough.
+	failover bool
+	// inPanic is true when the backends
+	// are all announced, healthy or not.
+	inPanic bool
+	// scaled is the number of instances the
+	// applications are scaled to, telling a
+	// scale to zero from a marathon failure.
+	scaled int32
+	// collected is the number of marathon
+	// targets of the last refresh.
+	collected int
+	// stale is true while the backends come from
+	// the snapshot, marathon being unreachable.
+	stale bool
+	// lookingUp is true while the agents are
+	// retrieved, lookedUp the last lookup time.
+	lookingUp bool
+	lookedUp  time.Time
+	located   chan struct{}
+	// staticVersion is the version of the
+	// static addresses last applied.
+	staticVersion int
+	marathon      *marathon.Client
+	updates       chan []*target
+	states        chan probeState
+	ctx           context.Context
+	cancel        context.CancelFunc
+}
+
+// backend is a service task address known by the poller. A backend is
+// a member of the resolved set as long as it is present in marathon, its
+// probe doesn't report a failure and, when required, its marathon health
+// checks are alive.
+type backend struct {
+	cancel   context.CancelFunc
+	metadata *Metadata
+	ready    bool
+	alive    bool
+	// probed is true once the probe
+	// reported the backend readiness.
+	probed bool
+	// gone is true when the backend is missing
+	// from marathon, its removal being deferred
+	// until the panic is over (see
+	// WithPanicThreshold).
+	gone bool
+}
+
+func (b *backend) member() bool {
+	return b.ready && b.alive
+}
+
+// probeState is a readiness reported by a backend probe
+type probeState struct {
+	addr    string
+	backend *backend
+	ready   bool
+}
+
+func newPoll(label string, m *marathon.Client, opts ...Option) (*poll, error) {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	p := &poll{
+		label:     label,
+		opts:      newOptions(opts...),
+		backends:  make(map[string]*backend),
+		announced: make(map[string]*Metadata),
+		updates:   make(chan []*target, 0),
+		states:    make(chan probeState, 0),
+		located:   make(chan struct{}),
+		ctx:       ctx,
+		cancel:    cancel,
+		marathon:  m,
 	}
 
-	if len(apps) != 1 {
-		return nil, errors.New("Duplicate labels or label not found")
+	if p.opts.stream == nil {
+		p.opts.stream = newStream(m, p.opts.logger)
 	}
 
-	// Parsing service port index
-	portIndex := int64(-1)
-	for k, v := range *apps[0].Labels {
-		if v == label {
-			res := strings.Split(k, "_")
-			if len(res) != 3 {
-				continue
-			}
+	entry, _ := p.staticEntry()
+	if entry != nil {
+		if err := entry.validate(); err != nil {
+			cancel()
+			return nil, err
+		}
 
-			portIndex, err = strconv.ParseInt(res[1], 10, 64)
-			if err != nil {
-				return nil, errors.New("Failed parse port index in tag")
-			}
+		if entry.mode() == StaticReplace {
+			// Marathon is ignored, the applications
+			// are discovered if the mode changes.
+			p.sources = make(map[string]*source)
+			return p, nil
+		}
+	}
+
+	sources, err := discover(ctx, m, label, p.opts.logger)
+	if err != nil {
+		if marathon.IsRetryable(err) && p.bootstrap(err) {
+			return p, nil
 		}
+
+		if entry == nil {
+			cancel()
+			return nil, err
+		}
+
+		p.opts.logger.Printf("couldn't discover the applications of %s: %v", label, err)
+	}
+
+	if len(sources) == 0 {
+		if entry == nil {
+			cancel()
+			return nil, errors.New("label not found")
+		}
+
+		sources = make(map[string]*source)
+	}
+
+	p.sources = sources
+
+	return p, nil
+}
+
+// staticEntry reloads the static addresses when they come from a file
+// and returns the entry of the name and its version, or nil if there is
+// none
+func (p *poll) staticEntry() (*StaticEntry, int) {
+	if p.opts.static == nil {
+		return nil, 0
+	}
+
+	if err := p.opts.static.reload(); err != nil {
+		p.opts.logger.Printf("couldn't load the static addresses: %v", err)
+	}
+
+	return p.opts.static.entry(p.label)
+}
+
+// bootstrap tracks the addresses of the snapshot when marathon is
+// unreachable. The backends are assumed ready until probed and are stale
+// until marathon is reachable again. It returns false if the snapshot has
+// no address for the name.
+func (p *poll) bootstrap(cause error) bool {
+	if p.opts.snapshot == nil {
+		return false
 	}
 
-	if portIndex < 0 {
-		return nil, errors.New("label not found")
+	addrs, err := p.opts.snapshot.load(p.label)
+	if err != nil {
+		p.opts.logger.Printf("couldn't load the snapshot of %s: %v", p.label, err)
+		return false
+	}
+
+	if len(addrs) == 0 {
+		return false
 	}
 
-	return &poll{
-		label:      label,
-		portIndex:  portIndex,
-		appID:      apps[0].ID,
-		probes:     make(map[string]*Probe),
-		updates:    make(chan []*marathon.Task, 0),
-		unregister: make(chan string, 0),
-		done:       make(chan bool, 1),
-		marathon:   m,
-	}, nil
+	p.opts.logger.Printf("marathon unreachable: %v. Resolving %s from the snapshot...", cause, p.label)
+
+	p.sources = make(map[string]*source)
+	p.stale = true
+
+	for addr, md := range addrs {
+		if b := p.track(addr, md, true); b != nil {
+			b.ready = true
+		}
+	}
+
+	return true
 }
 
-// poll polls the service tasks' states in marathon
+const (
+	// pollInterval is the default interval between two marathon polls
+	// when the event stream is unavailable
+	pollInterval = 1 * time.Second
+
+	// fallbackPollInterval is the interval between two marathon polls
+	// when the poller is subscribed to the event stream
+	fallbackPollInterval = 30 * time.Second
+
+	// discoveryInterval is the default interval between two lookups of
+	// the applications running the service
+	discoveryInterval = 30 * time.Second
+
+	// agentLookupInterval is the minimum interval between two lookups
+	// of the mesos agents, agentLookupTimeout the time allowed to each
+	agentLookupInterval = 30 * time.Second
+	agentLookupTimeout  = 5 * time.Second
+
+	// rediscoverDelay is the time the changes of the applications are
+	// gathered for, a burst of events causing a single rediscovery
+	rediscoverDelay = 500 * time.Millisecond
+)
+
+// poll polls the service tasks' states in marathon. The poller reacts to
+// the marathon event stream shared by the pollers of the resolver and
+// polls marathon as long as the stream isn't open. The applications
+// running the service are discovered again periodically and shortly after
+// an application or a pod running it, or which may run it, changes.
 func (p *poll) poll() {
-	ticker := time.NewTicker(1 * time.Second)
+	interval := p.opts.pollInterval
+
+	var discovery <-chan time.Time
+	if p.opts.discovery > 0 {
+		ticker := time.NewTicker(p.opts.discovery)
+		defer ticker.Stop()
+		discovery = ticker.C
+	}
+
+	var reload <-chan time.Time
+	if p.opts.static != nil && p.opts.static.path != "" {
+		ticker := time.NewTicker(p.opts.static.interval)
+		defer ticker.Stop()
+		reload = ticker.C
+	}
+
+	// The events are shared by the pollers of a resolver, marathon
+	// being polled until the stream is open. The subscription runs in
+	// the background, a hung event stream not delaying the first update.
+	events, stop := p.opts.stream.listen()
+	defer stop()
+
+	p.refresh()
+
+	// The timer is reset only once a poll ran, the
+	// events ignored not delaying the next poll.
+	timer := time.NewTimer(p.jitter(interval))
+	defer timer.Stop()
+
+	// changed fires once the changes
+	// were gathered (see rediscoverDelay).
+	var changed <-chan time.Time
 
 	for {
 		select {
-		case <-ticker.C:
-			tasks, err := p.marathon.Tasks(p.appID)
-			if err != nil {
-				fmt.Fprintf(os.Stderr, "couldn't retrieve tasks in marathon: %v. Trying again...", err)
+		case <-timer.C:
+		case <-discovery:
+			p.rediscover()
+		case <-changed:
+			changed = nil
+			p.rediscover()
+		case <-p.located:
+			// The targets held back are sent,
+			// located or not if the lookup failed.
+			p.lookingUp = false
+		case <-reload:
+			if _, version := p.staticEntry(); version == p.staticVersion {
 				continue
 			}
+		case event := <-events:
+			switch event.Type {
+			case eventStreamOpen, eventsLost:
+				// The events missed
+				// are caught up on.
+				interval = p.opts.resyncInterval
+				p.rediscover()
+			case marathon.EventAPIPost, marathon.EventAppTerminated,
+				marathon.EventPodCreated, marathon.EventPodUpdated,
+				marathon.EventPodDeleted, marathon.EventDeploymentSuccess:
+				if changed == nil && p.changes(event) {
+					changed = time.After(rediscoverDelay)
+				}
+				continue
+			default:
+				if appID := event.AppID(); appID != "" && p.sources[appID] == nil {
+					continue
+				}
+			}
+		case <-p.ctx.Done():
+			return
+		}
+
+		p.refresh()
+		p.reset(timer, interval)
+	}
+}
 
-			p.updates <- tasks
-		case <-p.done:
+// changes reports whether an event may change the applications running
+// the service: an application or a pod running it is updated or removed,
+// or an application labeled with the name is posted. The applications
+// started by a deployment may be labeled with the name as well. The pods
+// are ignored while applications run the service, discover preferring
+// them.
+func (p *poll) changes(event *marathon.Event) bool {
+	switch data := event.Data.(type) {
+	case *marathon.APIPostEvent:
+		app := data.AppDefinition
+		if app == nil || p.sources[app.ID] != nil {
+			return true
+		}
+
+		// A misconfigured application is reported.
+		port, err := appPort(app, p.label)
+		return port != nil || err != nil
+	case *marathon.AppTerminatedEvent:
+		return p.sources[data.AppID] != nil
+	case *marathon.PodEvent:
+		for _, s := range p.sources {
+			if s.pod == nil {
+				return false
+			}
+		}
+	case *marathon.DeploymentEvent:
+		if data.Plan == nil {
+			return true
+		}
+
+		for _, step := range data.Plan.Steps {
+			for _, action := range step.Actions {
+				switch {
+				case p.sources[action.App] != nil, p.sources[action.Pod] != nil:
+					return true
+				case action.Action == "StartApplication", action.Action == "StartPod":
+					return true
+				}
+			}
+		}
+
+		return false
+	}
+
+	return true
+}
+
+// reset restarts the poll timer with a jittered interval
+func (p *poll) reset(timer *time.Timer, interval time.Duration) {
+	if !timer.Stop() {
+		select {
+		case <-timer.C:
+		default:
+		}
+	}
+
+	timer.Reset(p.jitter(interval))
+}
+
+// jitter adds a random duration up to the configured jitter to an interval
+func (p *poll) jitter(interval time.Duration) time.Duration {
+	if p.opts.pollJitter <= 0 {
+		return interval
+	}
+
+	return interval + time.Duration(rand.Int63n(int64(p.opts.pollJitter)))
+}
+
+// rediscover updates the set of applications running the service
+func (p *poll) rediscover() {
+	sources, err := discover(p.ctx, p.marathon, p.label, p.opts.logger)
+	if err != nil {
+		if p.ctx.Err() == nil {
+			p.opts.logger.Printf("couldn't discover the applications of %s: %v", p.label, err)
+			p.opts.metrics.PollError(p.label)
+		}
+		return
+	}
+
+	for id := range sources {
+		if p.sources[id] == nil {
+			p.opts.logger.Printf("application %s added to %s", id, p.label)
+		}
+	}
+
+	for id := range p.sources {
+		if sources[id] == nil {
+			p.opts.logger.Printf("application %s removed from %s", id, p.label)
+		}
+	}
+
+	p.sources = sources
+}
+
+// refresh retrieves the instances of every applications running the
+// service in marathon, combines them with the static addresses and
+// forwards them to the poller. When an application isn't found anymore,
+// the applications are discovered again first so that a recreated
+// application replaces it in the same update.
+func (p *poll) refresh() {
+	entry, version := p.staticEntry()
+	p.staticVersion = version
+
+	if entry != nil && entry.mode() == StaticReplace {
+		atomic.StoreInt32(&p.scaled, 0)
+		p.send(entry.targets())
+		return
+	}
+
+	if p.stale {
+		// The applications are discovered first, the
+		// snapshot being kept until marathon responds.
+		sources, err := discover(p.ctx, p.marathon, p.label, p.opts.logger)
+		if err != nil {
+			if p.ctx.Err() == nil {
+				p.opts.logger.Printf("couldn't discover the applications of %s: %v", p.label, err)
+				p.opts.metrics.PollError(p.label)
+			}
+			return
+		}
+
+		p.opts.logger.Printf("marathon reachable, %s isn't resolved from the snapshot anymore", p.label)
+
+		p.sources = sources
+		p.stale = false
+	}
+
+	targets, missing, ok := p.collect()
+	if !ok {
+		return
+	}
+
+	// A vanished instance list is confirmed by the applications,
+	// scaled to zero, destroyed or not labeled anymore otherwise.
+	if missing || (len(targets) == 0 && p.collected > 0) {
+		p.rediscover()
+
+		if targets, _, ok = p.collect(); !ok {
 			return
 		}
 	}
+
+	p.collected = len(targets)
+
+	var scaled int
+	for _, s := range p.sources {
+		scaled += s.instances
+	}
+	atomic.StoreInt32(&p.scaled, int32(scaled))
+
+	// The targets are sent once their agents
+	// are known, their locality changing
+	// otherwise.
+	if !p.locate(targets) {
+		return
+	}
+
+	if entry != nil {
+		targets = entry.combine(targets)
+	}
+
+	p.send(targets)
+}
+
+// send forwards the targets to the poller
+func (p *poll) send(targets []*target) {
+	select {
+	case p.updates <- targets:
+	case <-p.ctx.Done():
+	}
+}
+
+// collect retrieves the instances of every applications running the
+// service. It reports whether an application wasn't found and fails if
+// the instances of an application couldn't be retrieved, the update
+// being incomplete.
+func (p *poll) collect() (targets []*target, missing bool, ok bool) {
+	targets = []*target{}
+
+	for id, s := range p.sources {
+		ts, err := s.targets(p.ctx, p.marathon)
+		switch {
+		case err == nil:
+		case p.ctx.Err() != nil:
+			return nil, false, false
+		case marathon.IsNotFound(err):
+			// The application has been destroyed,
+			// its tasks don't exist anymore.
+			p.opts.logger.Printf("application %s not found in marathon", id)
+			missing = true
+		case marathon.IsRetryable(err):
+			p.opts.logger.Printf("couldn't retrieve tasks in marathon: %v. Trying again...", err)
+			p.opts.metrics.PollError(p.label)
+			return nil, false, false
+		default:
+			p.opts.logger.Printf("couldn't retrieve tasks in marathon: %v", err)
+			p.opts.metrics.PollError(p.label)
+			return nil, false, false
+		}
+
+		targets = append(targets, ts...)
+	}
+
+	return targets, missing, true
+}
+
+// locate sets the zone and the rack of the targets given the attributes
+// of their mesos agent. The agents are read from the cache only, expired
+// agents being used until they are retrieved again in the background. It
+// returns false while the agents of some targets are being retrieved:
+// the poller refreshes once they are, the targets whose agent is still
+// unknown having no locality.
+func (p *poll) locate(targets []*target) bool {
+	if p.opts.mesosURI == "" {
+		return true
+	}
+
+	var missing, stale bool
+
+	for _, t := range targets {
+		if t.agentID == "" {
+			continue
+		}
+
+		agent, old := p.marathon.CachedAgent(t.agentID)
+		if agent == nil {
+			missing = true
+			continue
+		}
+
+		stale = stale || old
+
+		t.zone = agent.Attributes[p.opts.zoneAttribute]
+		t.rack = agent.Attributes[p.opts.rackAttribute]
+	}
+
+	if missing || stale {
+		p.lookupAgents()
+	}
+
+	return !missing || !p.lookingUp
+}
+
+// lookupAgents retrieves the agents of the mesos master in the
+// background, at most once per agentLookupInterval whether the lookup
+// failed or not. The poller refreshes once the lookup is over.
+func (p *poll) lookupAgents() {
+	if p.lookingUp || (!p.lookedUp.IsZero() && time.Since(p.lookedUp) < agentLookupInterval) {
+		return
+	}
+
+	p.lookingUp, p.lookedUp = true, time.Now()
+
+	go func() {
+		ctx, cancel := context.WithTimeout(p.ctx, agentLookupTimeout)
+		defer cancel()
+
+		_, err := p.marathon.AgentsContext(ctx)
+		if err != nil && p.ctx.Err() == nil {
+			p.opts.logger.Printf("couldn't retrieve the agents in mesos: %v", err)
+		}
+
+		select {
+		case p.located <- struct{}{}:
+		case <-p.ctx.Done():
+		}
+	}()
 }
 
-// Next blocks until an update or error happens in the service polling
+// Next blocks until an update or error happens in the service polling.
+// The backends bootstrapped from the snapshot are returned first.
 func (p *poll) Next() ([]*naming.Update, error) {
-	var ups []*naming.Update
+	ups := p.publish()
 
-	for {
+	for len(ups) == 0 {
 		select {
-		case tasks, ok := <-p.updates:
-			if !ok {
-				p.updates = nil
-				return nil, errors.New("poller unrecoverable")
+		case targets := <-p.updates:
+			ups = p.reconcile(targets)
+		case s := <-p.states:
+			ups = p.transition(s)
+		case <-p.ctx.Done():
+			if p.inPanic {
+				p.opts.metrics.Panic(p.label, false)
+				p.inPanic = false
 			}
 
-			for _, task := range tasks {
-				if _, ok := p.probes[task.Addr(p.portIndex)]; ok {
-					// If the task is already registered,
-					// there is nothing to do.
-					continue
-				}
+			return nil, errors.New("poller closed")
+		}
+	}
+
+	p.persist()
+
+	return ups, nil
+}
+
+// persist saves the announced addresses in the snapshot. The stale and
+// empty sets aren't saved, the snapshot keeping the last known good one.
+func (p *poll) persist() {
+	if p.opts.snapshot == nil || len(p.announced) == 0 {
+		return
+	}
+
+	for _, md := range p.announced {
+		if md.Stale {
+			return
+		}
+	}
+
+	if err := p.opts.snapshot.save(p.label, p.announced); err != nil {
+		p.opts.logger.Printf("couldn't save the snapshot of %s: %v", p.label, err)
+	}
+}
+
+// reconcile diffs the instances retrieved from marathon against the
+// current backend set. New addresses are probed and added while addresses
+// which disappeared from marathon are removed.
+func (p *poll) reconcile(targets []*target) []*naming.Update {
+	present := make(map[string]bool, len(targets))
 
-				go p.processTask(task)
+	for _, t := range targets {
+		addr := t.addr
+		present[addr] = true
 
-				ups = append(ups, &naming.Update{
-					Addr: task.Addr(p.portIndex),
-					Op:   naming.Add,
-				})
+		alive := !p.opts.healthChecks || t.alive
+
+		if b, ok := p.backends[addr]; ok {
+			b.gone = false
+
+			// If the task is already registered, only its
+			// health or its weight and locality may have
+			// changed. The address is announced again with
+			// a new metadata.
+			b.alive = alive
+
+			if md := t.metadata(); !b.metadata.equal(md) {
+				b.metadata = md
 			}
+			continue
+		}
+
+		// The backend is added once its
+		// probe reports it as ready.
+		p.track(addr, t.metadata(), alive)
+	}
+
+	for addr, b := range p.backends {
+		if present[addr] {
+			continue
+		}
+
+		if p.opts.panicThreshold > 0 {
+			// The task list may be truncated, the
+			// backend is removed when publishing
+			// unless the poller is in panic.
+			b.gone, b.alive = true, false
+			continue
+		}
+
+		b.cancel()
+		delete(p.backends, addr)
+	}
+
+	return p.publish()
+}
+
+// track starts probing a new backend. It returns nil if the probe
+// couldn't be instantiated.
+func (p *poll) track(addr string, md *Metadata, alive bool) *backend {
+	ctx, cancel := context.WithCancel(p.ctx)
+
+	out, err := p.opts.prober.Probe(ctx, p.label, addr)
+	if err != nil {
+		cancel()
+		p.opts.logger.Printf("unable to instantiate probe: %v", err)
+		p.opts.metrics.ProbeFailure(p.label)
+		return nil
+	}
+
+	b := &backend{
+		cancel:   cancel,
+		metadata: md,
+		alive:    alive,
+	}
+	p.backends[addr] = b
+
+	go p.monitor(addr, b, out)
+
+	return b
+}
+
+// transition applies a probe readiness to its backend. A backend is
+// removed when its probe fails and added back once it is ready again.
+func (p *poll) transition(s probeState) []*naming.Update {
+	b, ok := p.backends[s.addr]
+	if !ok || b != s.backend {
+		// The state belongs to a backend which
+		// has been removed in the meantime.
+		return nil
+	}
+
+	if !s.ready {
+		p.opts.metrics.ProbeFailure(p.label)
+	}
 
-			if len(ups) > 0 {
-				return ups, nil
+	if s.ready != b.ready {
+		p.opts.metrics.ProbeTransition(p.label, s.ready)
+	}
+
+	b.ready, b.probed = s.ready, true
+
+	return p.publish()
+}
+
+// publish returns the naming updates moving the announced addresses to
+// the selected ones. An address whose metadata changed is deleted and
+// added again, the balancers identifying the addresses by their metadata.
+func (p *poll) publish() []*naming.Update {
+	selected := p.selected()
+
+	var deleted, added []string
+
+	for addr, md := range p.announced {
+		if selected[addr] != md {
+			deleted = append(deleted, addr)
+		}
+	}
+
+	for addr, md := range selected {
+		if p.announced[addr] != md {
+			added = append(added, addr)
+		}
+	}
+
+	sort.Strings(deleted)
+	sort.Strings(added)
+
+	ups := make([]*naming.Update, 0, len(deleted)+len(added))
+
+	for _, addr := range deleted {
+		ups = append(ups, &naming.Update{Addr: addr, Op: naming.Delete, Metadata: p.announced[addr]})
+		delete(p.announced, addr)
+		p.opts.metrics.AddressDeleted(p.label)
+	}
+
+	for _, addr := range added {
+		ups = append(ups, &naming.Update{Addr: addr, Op: naming.Add, Metadata: selected[addr]})
+		p.announced[addr] = selected[addr]
+		p.opts.metrics.AddressAdded(p.label)
+	}
+
+	if len(ups) > 0 {
+		p.resolve(int32(len(p.announced)))
+	}
+
+	return ups
+}
+
+// resolve records the number of announced addresses and reports its
+// change to the metrics. A closed poller resolves none.
+func (p *poll) resolve(n int32) {
+	for {
+		prev := atomic.LoadInt32(&p.resolved)
+		if prev < 0 {
+			return
+		}
+
+		if atomic.CompareAndSwapInt32(&p.resolved, prev, n) {
+			if n != prev {
+				p.opts.metrics.Backends(p.label, int(n-prev))
 			}
-		case addr, ok := <-p.unregister:
-			if !ok {
-				p.unregister = nil
-				return nil, errors.New("poller unrecoverable")
+			return
+		}
+	}
+}
+
+// ready returns true if the poller announced at least one address
+func (p *poll) ready() bool {
+	return atomic.LoadInt32(&p.resolved) > 0
+}
+
+// selected returns the addresses to announce. When the ratio of the
+// probed backends which are members falls below the panic threshold, all
+// the probed backends are announced. With a locality, only the members
+// of the local zone are announced as long as the ratio of the local
+// backends which are members reaches the threshold. Otherwise all the
+// members are announced.
+func (p *poll) selected() map[string]*Metadata {
+	if p.panicking() {
+		all := make(map[string]*Metadata, len(p.backends))
+		for addr, b := range p.backends {
+			if b.probed {
+				all[addr] = b.metadata
 			}
+		}
 
-			delete(p.probes, addr)
+		return all
+	}
+
+	p.purge()
 
-			ups = append(ups, &naming.Update{
-				Addr: addr,
-				Op:   naming.Delete,
-			})
+	members := make(map[string]*Metadata)
+	locals := make(map[string]*Metadata)
 
-			return ups, nil
-		case <-p.done:
-			return ups, nil
+	var local int
+
+	for addr, b := range p.backends {
+		isLocal := p.opts.zone != "" && b.metadata.Zone == p.opts.zone
+		if isLocal {
+			local++
 		}
+
+		if !b.member() {
+			continue
+		}
+
+		members[addr] = b.metadata
+		if isLocal {
+			locals[addr] = b.metadata
+		}
+	}
+
+	if p.opts.zone == "" || len(members) == 0 {
+		return members
+	}
+
+	failover := len(locals) == 0 || float64(len(locals)) < p.opts.localityThreshold*float64(local)
+
+	if failover != p.failover {
+		if failover {
+			p.opts.logger.Printf("%d/%d backends of %s available in zone %s, failing over to all zones", len(locals), local, p.label, p.opts.zone)
+		} else {
+			p.opts.logger.Printf("%d/%d backends of %s available in zone %s, routing to the local zone", len(locals), local, p.label, p.opts.zone)
+		}
+		p.failover = failover
 	}
+
+	if failover {
+		return members
+	}
+
+	return locals
 }
 
-func (p *poll) processTask(task *marathon.Task) {
-	probe, err := newProbe(task.Addr(p.portIndex), time.Second*5)
-	if err != nil {
-		fmt.Fprintf(os.Stderr, "unable to instantiate probe: %v", err)
-		p.unregister <- probe.addr
-		return
+// purge removes the backends missing from marathon
+func (p *poll) purge() {
+	for addr, b := range p.backends {
+		if b.gone {
+			b.cancel()
+			delete(p.backends, addr)
+		}
 	}
+}
 
-	p.probes[probe.addr] = probe
+// panicking updates the panic mode given the ratio of the probed
+// backends present in marathon which are members. The backends not probed
+// yet are left aside, a starting poller not being in panic, as well as
+// the backends missing from marathon, a scale down not being a failure.
+// Marathon returning none of the probed backends is a panic though, unless
+// the applications are scaled to zero, destroyed or not labeled anymore.
+func (p *poll) panicking() bool {
+	if p.opts.panicThreshold <= 0 {
+		return false
+	}
 
-	out := probe.exec()
+	var probed, members, gone int
+	for _, b := range p.backends {
+		switch {
+		case !b.probed:
+			continue
+		case b.gone:
+			gone++
+			continue
+		}
+
+		probed++
+		if b.member() {
+			members++
+		}
+	}
+
+	inPanic := (probed == 0 && gone > 0 && atomic.LoadInt32(&p.scaled) > 0) ||
+		(probed > 0 && float64(members) < p.opts.panicThreshold*float64(probed))
+
+	if inPanic != p.inPanic {
+		if inPanic {
+			p.opts.logger.Printf("%d/%d backends of %s healthy, entering panic mode", members, probed, p.label)
+			p.opts.metrics.Panic(p.label, true)
+		} else {
+			p.opts.logger.Printf("%d/%d backends of %s healthy, leaving panic mode", members, probed, p.label)
+			p.opts.metrics.Panic(p.label, false)
+		}
+		p.inPanic = inPanic
+	}
+
+	return inPanic
+}
+
+// monitor forwards the probe readiness reports to the poller until the
+// probe or the poller is closed
+func (p *poll) monitor(addr string, b *backend, out <-chan bool) {
+	defer b.cancel()
 
 	for {
 		select {
-		case <-p.done:
-			probe.close()
+		case <-p.ctx.Done():
 			return
-		case state := <-out:
-			if state == connectivity.TransientFailure || state == connectivity.Shutdown {
-				p.unregister <- probe.addr
-				probe.close()
+		case ready, ok := <-out:
+			if !ok {
+				return
+			}
+
+			select {
+			case p.states <- probeState{addr: addr, backend: b, ready: ready}:
+			case <-p.ctx.Done():
 				return
 			}
 		}
 	}
-
 }
 
 func (p *poll) run() {
 	go p.poll()
 }
 
-// Close closes the polling and the probes monitoring
+// Close closes the polling and the probes monitoring. The marathon
+// requests in flight are canceled and the addresses resolved removed
+// from the metrics.
 func (p *poll) Close() {
-	close(p.done)
-	close(p.unregister)
-	close(p.updates)
+	p.cancel()
+
+	if n := atomic.SwapInt32(&p.resolved, -1); n > 0 {
+		p.opts.metrics.Backends(p.label, -int(n))
+	}
 }
@@ -1,20 +1,66 @@
 package resolver
 
 import (
+	"context"
+	"fmt"
+	"io/ioutil"
 	"net/http"
 	"net/http/httptest"
+	"os"
+	"path/filepath"
 	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
+	"time"
 
 	"github.com/eddyzags/resolver/marathon"
 
 	uuid "github.com/satori/go.uuid"
 	"github.com/stretchr/testify/assert"
 	"github.com/unrolled/render"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
 	"google.golang.org/grpc/naming"
 )
 
+// manualProber reports the readiness set by the tests
+type manualProber struct {
+	mu     sync.Mutex
+	probes map[string]chan bool
+}
+
+func newManualProber() *manualProber {
+	return &manualProber{probes: make(map[string]chan bool)}
+}
+
+// Probe implements Prober
+func (p *manualProber) Probe(ctx context.Context, name, addr string) (<-chan bool, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	out := make(chan bool)
+	p.probes[addr] = out
+
+	return out, nil
+}
+
+// set reports the readiness of an address once it is probed
+func (p *manualProber) set(addr string, ready bool) {
+	for {
+		p.mu.Lock()
+		out, ok := p.probes[addr]
+		p.mu.Unlock()
+
+		if ok {
+			out <- ready
+			return
+		}
+
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
 func TestPollInstantiationInstantiationWithoutError(t *testing.T) {
 	assert := assert.New(t)
 
@@ -44,8 +90,9 @@ func TestPollInstantiationInstantiationWithoutError(t *testing.T) {
 	poller, err := newPoll(val, marathonClient)
 	assert.NoError(err, "an unexpected error occured in poller instantiation")
 
-	assert.Equal(int64(2), poller.portIndex, "the port index should be equals")
-	assert.Equal(apps[0].ID, poller.appID, "the app id should be equals")
+	assert.Equal(1, len(poller.sources), "the number of sources should be 1")
+	assert.Equal(int64(2), poller.sources[apps[0].ID].portIndex, "the port index should be equals")
+	assert.Equal(apps[0].ID, poller.sources[apps[0].ID].id, "the app id should be equals")
 	assert.Equal(val, poller.label, "the label should be equals")
 }
 
@@ -71,7 +118,7 @@ func TestPollInstantiationWithErrorOnGetApplications(t *testing.T) {
 	assert.Nil(poller, "the poller should be nil")
 }
 
-func TestPollInstantiationWithErrorOnAppDuplicate(t *testing.T) {
+func TestPollInstantiationWithMultipleApps(t *testing.T) {
 	assert := assert.New(t)
 
 	key := "RESOLVER_2_NAME"
@@ -104,9 +151,55 @@ func TestPollInstantiationWithErrorOnAppDuplicate(t *testing.T) {
 	})
 
 	poller, err := newPoll(val, marathonClient)
-	assert.Error(err, "an error was expected in poller instantiation")
+	assert.NoError(err, "an unexpected error occured in poller instantiation")
 
-	assert.Nil(poller, "the poller should be nil")
+	assert.Equal(2, len(poller.sources), "the number of sources should be 2")
+	assert.NotNil(poller.sources["/test"], "the application should be a source")
+	assert.NotNil(poller.sources["/test-2"], "the application should be a source")
+}
+
+func TestPollInstantiationWithPortLabel(t *testing.T) {
+	assert := assert.New(t)
+
+	val := "service-test"
+
+	apps := []*marathon.Application{
+		{
+			ID: "/test",
+			PortDefinitions: &[]marathon.PortDefinition{
+				{Name: "http"},
+				{Name: "grpc", Labels: &map[string]string{"RESOLVER_NAME": val}},
+			},
+		},
+		{
+			ID:     "/other",
+			Labels: &map[string]string{"RESOLVER_0_NAME": "other"},
+		},
+	}
+
+	handler := http.HandlerFunc(func(rw http.ResponseWriter, rq *http.Request) {
+		// Like marathon, the label selector
+		// only matches the application labels.
+		if rq.URL.Query().Get("label") != "" {
+			render.New().JSON(rw, http.StatusOK, []*marathon.Application{})
+			return
+		}
+
+		render.New().JSON(rw, http.StatusOK, apps)
+	})
+
+	ts := httptest.NewServer(handler)
+	defer ts.Close()
+
+	marathonClient := marathon.NewClient(&marathon.Config{
+		URI: ts.URL,
+	})
+
+	poller, err := newPoll(val, marathonClient)
+	assert.NoError(err, "an unexpected error occured in poller instantiation")
+
+	assert.Equal(1, len(poller.sources), "the number of sources should be 1")
+	assert.Equal(int64(1), poller.sources["/test"].portIndex, "the port indexes should be equals")
 }
 
 func TestPollInstantiationWithErrorOnAppNotFound(t *testing.T) {
@@ -162,6 +255,43 @@ func TestPollInstantiationWithErrorOnLabelSyntax(t *testing.T) {
 	assert.Nil(poller, "the poller should be nil")
 }
 
+func TestPollInstantiationWithMisconfiguredApp(t *testing.T) {
+	assert := assert.New(t)
+
+	val := "service-test"
+
+	apps := []*marathon.Application{
+		{
+			ID:     "/test",
+			Labels: &map[string]string{"RESOLVER_0_NAME": val},
+		},
+		{
+			ID:     "/misconfigured",
+			Labels: &map[string]string{"RESOLVER_N_NAME": val},
+		},
+	}
+
+	handler := http.HandlerFunc(func(rw http.ResponseWriter, rq *http.Request) {
+		render.New().JSON(rw, http.StatusOK, apps)
+	})
+
+	ts := httptest.NewServer(handler)
+	defer ts.Close()
+
+	marathonClient := marathon.NewClient(&marathon.Config{
+		URI: ts.URL,
+	})
+
+	l := &logger{}
+
+	poller, err := newPoll(val, marathonClient, WithLogger(l))
+	assert.NoError(err, "an unexpected error occured in poller instantiation")
+
+	assert.Equal(1, len(poller.sources), "the number of sources should be 1")
+	assert.NotNil(poller.sources["/test"], "the valid application should be discovered")
+	assert.True(l.contains("application /misconfigured skipped"), "the misconfigured application should be logged")
+}
+
 func TestPollInstantiationWithErrorOnLabelPortIndexSyntax(t *testing.T) {
 	assert := assert.New(t)
 
@@ -264,8 +394,8 @@ func TestPollNextAddWithoutError(t *testing.T) {
 		switch rq.URL.Path {
 		case "/v2/apps":
 			render.New().JSON(rw, http.StatusOK, apps)
-		case "/v2/tasks":
-			render.New().JSON(rw, http.StatusOK, tasks)
+		case "/v2/apps/test/tasks":
+			render.New().JSON(rw, http.StatusOK, map[string]interface{}{"tasks": tasks})
 		}
 	})
 
@@ -332,8 +462,8 @@ func TestPollNextDeleteWithoutError(t *testing.T) {
 		switch rq.URL.Path {
 		case "/v2/apps":
 			render.New().JSON(rw, http.StatusOK, apps)
-		case "/v2/tasks":
-			render.New().JSON(rw, http.StatusOK, tasks)
+		case "/v2/apps/test/tasks":
+			render.New().JSON(rw, http.StatusOK, map[string]interface{}{"tasks": tasks})
 		}
 	})
 
@@ -362,3 +492,2158 @@ func TestPollNextDeleteWithoutError(t *testing.T) {
 	assert.Equal(naming.Delete, ups[0].Op, "the operations should be equals")
 	assert.Equal(addr, ups[0].Addr, "the app id should be equals")
 }
+
+func TestPollNextAddOnStatusUpdateEvent(t *testing.T) {
+	assert := assert.New(t)
+
+	grpcServer, addr, err := newGRPCServer()
+	assert.NoError(err, "an unexpected error occured in grpc server instantiation")
+
+	defer grpcServer.Stop()
+
+	key := "RESOLVER_0_NAME"
+	val := "service-test"
+
+	port, err := strconv.ParseInt(strings.Split(addr, ":")[1], 10, 32)
+	assert.NoError(err, "an unexpected error occured in grpc server port parsing")
+
+	apps := []*marathon.Application{
+		{
+			ID: "/test",
+			Labels: &map[string]string{
+				key: val,
+			},
+		},
+	}
+
+	tasks := []*marathon.Task{
+		{
+			ID:    uuid.Must(uuid.NewV4()).String(),
+			AppID: apps[0].ID,
+			Host:  "127.0.0.1",
+			Ports: []int{
+				int(port),
+			},
+		},
+	}
+
+	var started int32
+
+	handler := http.HandlerFunc(func(rw http.ResponseWriter, rq *http.Request) {
+		switch rq.URL.Path {
+		case "/v2/apps":
+			render.New().JSON(rw, http.StatusOK, apps)
+		case "/v2/apps/test/tasks":
+			if atomic.LoadInt32(&started) == 0 {
+				render.New().JSON(rw, http.StatusOK, map[string]interface{}{"tasks": []*marathon.Task{}})
+				return
+			}
+			render.New().JSON(rw, http.StatusOK, map[string]interface{}{"tasks": tasks})
+		case "/v2/events":
+			rw.Header().Set("Content-Type", "text/event-stream")
+			rw.WriteHeader(http.StatusOK)
+			rw.(http.Flusher).Flush()
+
+			time.Sleep(100 * time.Millisecond)
+			atomic.StoreInt32(&started, 1)
+
+			fmt.Fprintf(rw, "event: %s\ndata: {\"eventType\":\"%s\",\"appId\":\"%s\",\"taskStatus\":\"TASK_RUNNING\"}\n\n",
+				marathon.EventStatusUpdate, marathon.EventStatusUpdate, apps[0].ID)
+			rw.(http.Flusher).Flush()
+
+			select {
+			case <-rq.Context().Done():
+			case <-time.After(5 * time.Second):
+			}
+		}
+	})
+
+	ts := httptest.NewServer(handler)
+	defer ts.Close()
+
+	marathonClient := marathon.NewClient(&marathon.Config{
+		URI: ts.URL,
+	})
+
+	poller, err := newPoll(val, marathonClient)
+	assert.NoError(err, "an unexpected error occured in poller instantiation")
+	defer poller.Close()
+
+	poller.run()
+
+	start := time.Now()
+
+	ups, err := poller.Next()
+	assert.NoError(err, "an unexpected error occured in poller next")
+
+	assert.True(time.Since(start) < fallbackPollInterval, "the update should be triggered by the event")
+	assert.Equal(1, len(ups), "The number of updates should be 1")
+	assert.Equal(naming.Add, ups[0].Op, "the operations should be equals")
+	assert.Equal(addr, ups[0].Addr, "the addresses should be equals")
+}
+
+func TestPollNextDeleteOnTaskRemoved(t *testing.T) {
+	assert := assert.New(t)
+
+	grpcServer, addr, err := newGRPCServer()
+	assert.NoError(err, "an unexpected error occured in grpc server instantiation")
+
+	defer grpcServer.Stop()
+
+	key := "RESOLVER_0_NAME"
+	val := "service-test"
+
+	port, err := strconv.ParseInt(strings.Split(addr, ":")[1], 10, 32)
+	assert.NoError(err, "an unexpected error occured in grpc server port parsing")
+
+	apps := []*marathon.Application{
+		{
+			ID: "/test",
+			Labels: &map[string]string{
+				key: val,
+			},
+		},
+	}
+
+	tasks := []*marathon.Task{
+		{
+			ID:    uuid.Must(uuid.NewV4()).String(),
+			AppID: apps[0].ID,
+			Host:  "127.0.0.1",
+			Ports: []int{
+				int(port),
+			},
+		},
+	}
+
+	var killed int32
+
+	handler := http.HandlerFunc(func(rw http.ResponseWriter, rq *http.Request) {
+		switch rq.URL.Path {
+		case "/v2/apps":
+			render.New().JSON(rw, http.StatusOK, apps)
+		case "/v2/apps/test/tasks":
+			if atomic.LoadInt32(&killed) == 1 {
+				render.New().JSON(rw, http.StatusOK, map[string]interface{}{"tasks": []*marathon.Task{}})
+				return
+			}
+			render.New().JSON(rw, http.StatusOK, map[string]interface{}{"tasks": tasks})
+		}
+	})
+
+	ts := httptest.NewServer(handler)
+	defer ts.Close()
+
+	marathonClient := marathon.NewClient(&marathon.Config{
+		URI: ts.URL,
+	})
+
+	poller, err := newPoll(val, marathonClient)
+	assert.NoError(err, "an unexpected error occured in poller instantiation")
+	defer poller.Close()
+
+	poller.run()
+
+	ups, err := poller.Next()
+	assert.NoError(err, "an unexpected error occured in poller next")
+
+	assert.Equal(1, len(ups), "The number of updates should be 1")
+	assert.Equal(naming.Add, ups[0].Op, "the operations should be equals")
+
+	// The grpc server is still reachable but marathon
+	// doesn't know the task anymore.
+	atomic.StoreInt32(&killed, 1)
+
+	ups, err = poller.Next()
+	assert.NoError(err, "an unexpected error occured in poller next")
+
+	assert.Equal(1, len(ups), "The number of updates should be 1")
+	assert.Equal(naming.Delete, ups[0].Op, "the operations should be equals")
+	assert.Equal(addr, ups[0].Addr, "the addresses should be equals")
+}
+
+func TestPollNextErrorOnClose(t *testing.T) {
+	assert := assert.New(t)
+
+	key := "RESOLVER_0_NAME"
+	val := "service-test"
+
+	apps := []*marathon.Application{
+		{
+			ID: "/test",
+			Labels: &map[string]string{
+				key: val,
+			},
+		},
+	}
+
+	handler := http.HandlerFunc(func(rw http.ResponseWriter, rq *http.Request) {
+		render.New().JSON(rw, http.StatusOK, apps)
+	})
+
+	ts := httptest.NewServer(handler)
+	defer ts.Close()
+
+	marathonClient := marathon.NewClient(&marathon.Config{
+		URI: ts.URL,
+	})
+
+	poller, err := newPoll(val, marathonClient)
+	assert.NoError(err, "an unexpected error occured in poller instantiation")
+
+	poller.Close()
+
+	ups, err := poller.Next()
+	assert.Error(err, "an error was expected in poller next")
+
+	assert.Nil(ups, "the updates should be nil")
+}
+
+func TestPollNextWithMarathonHealthChecks(t *testing.T) {
+	assert := assert.New(t)
+
+	grpcServer, addr, err := newGRPCServer()
+	assert.NoError(err, "an unexpected error occured in grpc server instantiation")
+
+	defer grpcServer.Stop()
+
+	key := "RESOLVER_0_NAME"
+	val := "service-test"
+
+	port, err := strconv.ParseInt(strings.Split(addr, ":")[1], 10, 32)
+	assert.NoError(err, "an unexpected error occured in grpc server port parsing")
+
+	apps := []*marathon.Application{
+		{
+			ID: "/test",
+			Labels: &map[string]string{
+				key: val,
+			},
+			HealthChecks: []marathon.HealthCheck{
+				{
+					Protocol: "TCP",
+				},
+			},
+		},
+	}
+
+	var alive int32
+
+	handler := http.HandlerFunc(func(rw http.ResponseWriter, rq *http.Request) {
+		switch rq.URL.Path {
+		case "/v2/apps":
+			render.New().JSON(rw, http.StatusOK, apps)
+		case "/v2/apps/test/tasks":
+			tasks := []*marathon.Task{
+				{
+					ID:    "test.1",
+					AppID: apps[0].ID,
+					Host:  "127.0.0.1",
+					Ports: []int{
+						int(port),
+					},
+					State: marathon.TaskRunning,
+					HealthCheckResults: []marathon.HealthCheckResult{
+						{
+							Alive: atomic.LoadInt32(&alive) == 1,
+						},
+					},
+				},
+			}
+			render.New().JSON(rw, http.StatusOK, map[string]interface{}{"tasks": tasks})
+		}
+	})
+
+	ts := httptest.NewServer(handler)
+	defer ts.Close()
+
+	marathonClient := marathon.NewClient(&marathon.Config{
+		URI: ts.URL,
+	})
+
+	poller, err := newPoll(val, marathonClient, WithMarathonHealthChecks())
+	assert.NoError(err, "an unexpected error occured in poller instantiation")
+	defer poller.Close()
+
+	poller.run()
+
+	go func() {
+		time.Sleep(1500 * time.Millisecond)
+		atomic.StoreInt32(&alive, 1)
+	}()
+
+	start := time.Now()
+
+	ups, err := poller.Next()
+	assert.NoError(err, "an unexpected error occured in poller next")
+
+	assert.True(time.Since(start) > time.Second, "the task shouldn't be added before its health checks are alive")
+	assert.Equal(1, len(ups), "The number of updates should be 1")
+	assert.Equal(naming.Add, ups[0].Op, "the operations should be equals")
+	assert.Equal(addr, ups[0].Addr, "the addresses should be equals")
+
+	atomic.StoreInt32(&alive, 0)
+
+	ups, err = poller.Next()
+	assert.NoError(err, "an unexpected error occured in poller next")
+
+	assert.Equal(1, len(ups), "The number of updates should be 1")
+	assert.Equal(naming.Delete, ups[0].Op, "the operations should be equals")
+	assert.Equal(addr, ups[0].Addr, "the addresses should be equals")
+}
+
+func TestPollNextWithGRPCHealthCheck(t *testing.T) {
+	assert := assert.New(t)
+
+	grpcServer, healthServer, addr, err := newGRPCHealthServer()
+	assert.NoError(err, "an unexpected error occured in grpc server instantiation")
+
+	defer grpcServer.Stop()
+
+	healthServer.SetServingStatus("helloworld", healthpb.HealthCheckResponse_NOT_SERVING)
+
+	key := "RESOLVER_0_NAME"
+	val := "service-test"
+
+	port, err := strconv.ParseInt(strings.Split(addr, ":")[1], 10, 32)
+	assert.NoError(err, "an unexpected error occured in grpc server port parsing")
+
+	apps := []*marathon.Application{
+		{
+			ID: "/test",
+			Labels: &map[string]string{
+				key: val,
+			},
+		},
+	}
+
+	tasks := []*marathon.Task{
+		{
+			ID:    uuid.Must(uuid.NewV4()).String(),
+			AppID: apps[0].ID,
+			Host:  "127.0.0.1",
+			Ports: []int{
+				int(port),
+			},
+		},
+	}
+
+	handler := http.HandlerFunc(func(rw http.ResponseWriter, rq *http.Request) {
+		switch rq.URL.Path {
+		case "/v2/apps":
+			render.New().JSON(rw, http.StatusOK, apps)
+		case "/v2/apps/test/tasks":
+			render.New().JSON(rw, http.StatusOK, map[string]interface{}{"tasks": tasks})
+		}
+	})
+
+	ts := httptest.NewServer(handler)
+	defer ts.Close()
+
+	marathonClient := marathon.NewClient(&marathon.Config{
+		URI: ts.URL,
+	})
+
+	poller, err := newPoll(val, marathonClient, WithGRPCHealthCheck(map[string]string{
+		val: "helloworld",
+	}))
+	assert.NoError(err, "an unexpected error occured in poller instantiation")
+	defer poller.Close()
+
+	poller.run()
+
+	go func() {
+		time.Sleep(500 * time.Millisecond)
+		healthServer.SetServingStatus("helloworld", healthpb.HealthCheckResponse_SERVING)
+	}()
+
+	start := time.Now()
+
+	ups, err := poller.Next()
+	assert.NoError(err, "an unexpected error occured in poller next")
+
+	assert.True(time.Since(start) > 400*time.Millisecond, "the backend shouldn't be added before serving")
+	assert.Equal(1, len(ups), "The number of updates should be 1")
+	assert.Equal(naming.Add, ups[0].Op, "the operations should be equals")
+	assert.Equal(addr, ups[0].Addr, "the addresses should be equals")
+
+	healthServer.SetServingStatus("helloworld", healthpb.HealthCheckResponse_NOT_SERVING)
+
+	ups, err = poller.Next()
+	assert.NoError(err, "an unexpected error occured in poller next")
+
+	assert.Equal(1, len(ups), "The number of updates should be 1")
+	assert.Equal(naming.Delete, ups[0].Op, "the operations should be equals")
+}
+
+func TestPollCloseCancelsRequests(t *testing.T) {
+	assert := assert.New(t)
+
+	key := "RESOLVER_0_NAME"
+	val := "service-test"
+
+	apps := []*marathon.Application{
+		{
+			ID: "/test",
+			Labels: &map[string]string{
+				key: val,
+			},
+		},
+	}
+
+	pending := make(chan struct{})
+	canceled := make(chan struct{})
+
+	handler := http.HandlerFunc(func(rw http.ResponseWriter, rq *http.Request) {
+		switch rq.URL.Path {
+		case "/v2/apps":
+			render.New().JSON(rw, http.StatusOK, apps)
+		case "/v2/apps/test/tasks":
+			close(pending)
+			<-rq.Context().Done()
+			close(canceled)
+		}
+	})
+
+	ts := httptest.NewServer(handler)
+	defer ts.Close()
+
+	marathonClient := marathon.NewClient(&marathon.Config{
+		URI: ts.URL,
+	})
+
+	poller, err := newPoll(val, marathonClient)
+	assert.NoError(err, "an unexpected error occured in poller instantiation")
+
+	poller.run()
+
+	<-pending
+
+	poller.Close()
+
+	select {
+	case <-canceled:
+	case <-time.After(5 * time.Second):
+		t.Fatal("the request in flight should be canceled")
+	}
+}
+
+func TestPollNextWithHungEventStream(t *testing.T) {
+	assert := assert.New(t)
+
+	grpcServer, addr, err := newGRPCServer()
+	assert.NoError(err, "an unexpected error occured in grpc server instantiation")
+
+	defer grpcServer.Stop()
+
+	val := "service-test"
+
+	port, err := strconv.ParseInt(strings.Split(addr, ":")[1], 10, 32)
+	assert.NoError(err, "an unexpected error occured in grpc server port parsing")
+
+	apps := []*marathon.Application{
+		{
+			ID:     "/test",
+			Labels: &map[string]string{"RESOLVER_0_NAME": val},
+		},
+	}
+
+	tasks := []*marathon.Task{
+		{ID: "test.1", AppID: apps[0].ID, Host: "127.0.0.1", Ports: []int{int(port)}},
+	}
+
+	handler := http.HandlerFunc(func(rw http.ResponseWriter, rq *http.Request) {
+		switch rq.URL.Path {
+		case "/v2/apps":
+			render.New().JSON(rw, http.StatusOK, apps)
+		case "/v2/apps/test/tasks":
+			render.New().JSON(rw, http.StatusOK, map[string]interface{}{"tasks": tasks})
+		case "/v2/events":
+			// The connection is accepted
+			// but never answered.
+			<-rq.Context().Done()
+		}
+	})
+
+	ts := httptest.NewServer(handler)
+	defer ts.Close()
+
+	marathonClient := marathon.NewClient(&marathon.Config{
+		URI:            ts.URL,
+		RequestTimeout: 200 * time.Millisecond,
+	})
+
+	poller, err := newPoll(val, marathonClient)
+	assert.NoError(err, "an unexpected error occured in poller instantiation")
+
+	poller.run()
+	defer poller.Close()
+
+	ups := make(chan []*naming.Update, 1)
+	go func() {
+		u, _ := poller.Next()
+		ups <- u
+	}()
+
+	select {
+	case u := <-ups:
+		assert.Equal(1, len(u), "The number of updates should be 1")
+		assert.Equal(addr, u[0].Addr, "the addresses should be equals")
+	case <-time.After(5 * time.Second):
+		t.Fatal("the poller should resolve the name without the event stream")
+	}
+}
+
+func TestPollNextDeleteOnApplicationNotFound(t *testing.T) {
+	assert := assert.New(t)
+
+	grpcServer, addr, err := newGRPCServer()
+	assert.NoError(err, "an unexpected error occured in grpc server instantiation")
+
+	defer grpcServer.Stop()
+
+	key := "RESOLVER_0_NAME"
+	val := "service-test"
+
+	port, err := strconv.ParseInt(strings.Split(addr, ":")[1], 10, 32)
+	assert.NoError(err, "an unexpected error occured in grpc server port parsing")
+
+	apps := []*marathon.Application{
+		{
+			ID: "/test",
+			Labels: &map[string]string{
+				key: val,
+			},
+		},
+	}
+
+	tasks := []*marathon.Task{
+		{
+			ID:    uuid.Must(uuid.NewV4()).String(),
+			AppID: apps[0].ID,
+			Host:  "127.0.0.1",
+			Ports: []int{
+				int(port),
+			},
+		},
+	}
+
+	var destroyed int32
+
+	handler := http.HandlerFunc(func(rw http.ResponseWriter, rq *http.Request) {
+		switch rq.URL.Path {
+		case "/v2/apps":
+			render.New().JSON(rw, http.StatusOK, apps)
+		case "/v2/apps/test/tasks":
+			if atomic.LoadInt32(&destroyed) == 1 {
+				render.New().JSON(rw, http.StatusNotFound, map[string]string{"message": "App '/test' does not exist"})
+				return
+			}
+			render.New().JSON(rw, http.StatusOK, map[string]interface{}{"tasks": tasks})
+		}
+	})
+
+	ts := httptest.NewServer(handler)
+	defer ts.Close()
+
+	marathonClient := marathon.NewClient(&marathon.Config{
+		URI: ts.URL,
+	})
+
+	poller, err := newPoll(val, marathonClient)
+	assert.NoError(err, "an unexpected error occured in poller instantiation")
+	defer poller.Close()
+
+	poller.run()
+
+	_, err = poller.Next()
+	assert.NoError(err, "an unexpected error occured in poller next")
+
+	atomic.StoreInt32(&destroyed, 1)
+
+	ups, err := poller.Next()
+	assert.NoError(err, "an unexpected error occured in poller next")
+
+	assert.Equal(1, len(ups), "The number of updates should be 1")
+	assert.Equal(naming.Delete, ups[0].Op, "the operations should be equals")
+	assert.Equal(addr, ups[0].Addr, "the addresses should be equals")
+}
+
+func TestPollNextPodWithoutError(t *testing.T) {
+	assert := assert.New(t)
+
+	grpcServer, addr, err := newGRPCServer()
+	assert.NoError(err, "an unexpected error occured in grpc server instantiation")
+
+	defer grpcServer.Stop()
+
+	val := "service-test"
+
+	port, err := strconv.ParseInt(strings.Split(addr, ":")[1], 10, 32)
+	assert.NoError(err, "an unexpected error occured in grpc server port parsing")
+
+	pods := []*marathon.Pod{
+		{
+			ID: "/web",
+			Labels: &map[string]string{
+				"RESOLVER_1_NAME": val,
+			},
+			Containers: []*marathon.PodContainer{
+				{
+					Name:      "proxy",
+					Endpoints: []*marathon.PodEndpoint{{Name: "http"}},
+				},
+				{
+					Name:      "server",
+					Endpoints: []*marathon.PodEndpoint{{Name: "grpc"}},
+				},
+			},
+		},
+	}
+
+	healthy := true
+
+	status := &marathon.PodStatus{
+		ID: "/web",
+		Instances: []*marathon.PodInstance{
+			{
+				ID:            uuid.Must(uuid.NewV4()).String(),
+				AgentHostname: "127.0.0.1",
+				Containers: []*marathon.PodContainerStatus{
+					{
+						Name:      "proxy",
+						Status:    marathon.TaskRunning,
+						Endpoints: []*marathon.PodEndpointStatus{{Name: "http", AllocatedHostPort: 2221}},
+					},
+					{
+						Name:      "server",
+						Status:    marathon.TaskRunning,
+						Endpoints: []*marathon.PodEndpointStatus{{Name: "grpc", AllocatedHostPort: int(port), Healthy: &healthy}},
+					},
+				},
+			},
+		},
+	}
+
+	handler := http.HandlerFunc(func(rw http.ResponseWriter, rq *http.Request) {
+		switch rq.URL.Path {
+		case "/v2/apps":
+			render.New().JSON(rw, http.StatusOK, []*marathon.Application{})
+		case "/v2/pods":
+			render.New().JSON(rw, http.StatusOK, pods)
+		case "/v2/pods/web::status":
+			render.New().JSON(rw, http.StatusOK, status)
+		default:
+			rw.WriteHeader(http.StatusNotFound)
+		}
+	})
+
+	ts := httptest.NewServer(handler)
+	defer ts.Close()
+
+	marathonClient := marathon.NewClient(&marathon.Config{
+		URI: ts.URL,
+	})
+
+	poller, err := newPoll(val, marathonClient)
+	assert.NoError(err, "an unexpected error occured in poller instantiation")
+
+	assert.NotNil(poller.sources["/web"], "the pod should be a source")
+	assert.Equal("server", poller.sources["/web"].pod.container, "the container names should be equals")
+	assert.Equal("grpc", poller.sources["/web"].pod.endpoint.Name, "the endpoint names should be equals")
+
+	poller.run()
+	defer poller.Close()
+
+	ups, err := poller.Next()
+	assert.NoError(err, "an unexpected error occured in poller next")
+
+	assert.Equal(1, len(ups), "The number of updates should be 1")
+	assert.Equal(naming.Add, ups[0].Op, "the operations should be equals")
+	assert.Equal(addr, ups[0].Addr, "the addresses should be equals")
+}
+
+func TestPollInstantiationWithErrorOnPodEndpointNotFound(t *testing.T) {
+	assert := assert.New(t)
+
+	val := "service-test"
+
+	pods := []*marathon.Pod{
+		{
+			ID: "/web",
+			Labels: &map[string]string{
+				"RESOLVER_3_NAME": val,
+			},
+			Containers: []*marathon.PodContainer{
+				{
+					Name:      "server",
+					Endpoints: []*marathon.PodEndpoint{{Name: "grpc"}},
+				},
+			},
+		},
+	}
+
+	handler := http.HandlerFunc(func(rw http.ResponseWriter, rq *http.Request) {
+		switch rq.URL.Path {
+		case "/v2/pods":
+			render.New().JSON(rw, http.StatusOK, pods)
+		default:
+			render.New().JSON(rw, http.StatusOK, []*marathon.Application{})
+		}
+	})
+
+	ts := httptest.NewServer(handler)
+	defer ts.Close()
+
+	marathonClient := marathon.NewClient(&marathon.Config{
+		URI: ts.URL,
+	})
+
+	poller, err := newPoll(val, marathonClient)
+	assert.Error(err, "an error was expected in poller instantiation")
+
+	assert.Nil(poller, "the poller should be nil")
+}
+
+func TestPollNextContainerNetworkingWithoutError(t *testing.T) {
+	assert := assert.New(t)
+
+	grpcServer, addr, err := newGRPCServer()
+	assert.NoError(err, "an unexpected error occured in grpc server instantiation")
+
+	defer grpcServer.Stop()
+
+	val := "service-test"
+
+	port, err := strconv.ParseInt(strings.Split(addr, ":")[1], 10, 32)
+	assert.NoError(err, "an unexpected error occured in grpc server port parsing")
+
+	apps := []*marathon.Application{
+		{
+			ID:       "/test",
+			Networks: []marathon.Network{{Mode: marathon.NetworkContainer, Name: "dcos"}},
+			Container: &marathon.Container{
+				PortMappings: &[]marathon.PortMapping{
+					{
+						Name:          "grpc",
+						ContainerPort: int(port),
+						Labels:        &map[string]string{"RESOLVER_NAME": val},
+					},
+				},
+			},
+		},
+	}
+
+	tasks := []*marathon.Task{
+		{
+			ID:    uuid.Must(uuid.NewV4()).String(),
+			AppID: apps[0].ID,
+			Host:  "10.0.0.1",
+			IPAddresses: []marathon.IPAddress{
+				{IPAddress: "127.0.0.1", Protocol: "IPv4"},
+			},
+		},
+		{
+			// The task has no ip address yet
+			ID:    uuid.Must(uuid.NewV4()).String(),
+			AppID: apps[0].ID,
+			Host:  "10.0.0.2",
+		},
+	}
+
+	handler := http.HandlerFunc(func(rw http.ResponseWriter, rq *http.Request) {
+		switch rq.URL.Path {
+		case "/v2/apps":
+			render.New().JSON(rw, http.StatusOK, apps)
+		case "/v2/apps/test/tasks":
+			render.New().JSON(rw, http.StatusOK, map[string]interface{}{"tasks": tasks})
+		default:
+			rw.WriteHeader(http.StatusNotFound)
+		}
+	})
+
+	ts := httptest.NewServer(handler)
+	defer ts.Close()
+
+	marathonClient := marathon.NewClient(&marathon.Config{
+		URI: ts.URL,
+	})
+
+	poller, err := newPoll(val, marathonClient)
+	assert.NoError(err, "an unexpected error occured in poller instantiation")
+
+	assert.Equal(networkContainer, poller.sources["/test"].network, "the networks should be equals")
+
+	poller.run()
+	defer poller.Close()
+
+	ups, err := poller.Next()
+	assert.NoError(err, "an unexpected error occured in poller next")
+
+	assert.Equal(1, len(ups), "The number of updates should be 1")
+	assert.Equal(naming.Add, ups[0].Op, "the operations should be equals")
+	assert.Equal(addr, ups[0].Addr, "the addresses should be equals")
+}
+
+func TestPollNextAddOnApplicationAdded(t *testing.T) {
+	assert := assert.New(t)
+
+	blueServer, blueAddr, err := newGRPCServer()
+	assert.NoError(err, "an unexpected error occured in grpc server instantiation")
+
+	defer blueServer.Stop()
+
+	greenServer, greenAddr, err := newGRPCServer()
+	assert.NoError(err, "an unexpected error occured in grpc server instantiation")
+
+	defer greenServer.Stop()
+
+	val := "service-test"
+
+	blue := &marathon.Application{
+		ID:     "/svc-blue",
+		Labels: &map[string]string{"RESOLVER_0_NAME": val},
+	}
+
+	green := &marathon.Application{
+		ID:     "/svc-green",
+		Labels: &map[string]string{"RESOLVER_0_NAME": val},
+	}
+
+	tasks := func(app *marathon.Application, addr string) map[string]interface{} {
+		port, _ := strconv.Atoi(strings.Split(addr, ":")[1])
+
+		return map[string]interface{}{
+			"tasks": []*marathon.Task{
+				{
+					ID:    uuid.Must(uuid.NewV4()).String(),
+					AppID: app.ID,
+					Host:  "127.0.0.1",
+					Ports: []int{port},
+				},
+			},
+		}
+	}
+
+	var deployed int32
+
+	handler := http.HandlerFunc(func(rw http.ResponseWriter, rq *http.Request) {
+		switch rq.URL.Path {
+		case "/v2/apps":
+			if atomic.LoadInt32(&deployed) == 0 {
+				render.New().JSON(rw, http.StatusOK, []*marathon.Application{blue})
+				return
+			}
+			render.New().JSON(rw, http.StatusOK, []*marathon.Application{blue, green})
+		case "/v2/apps/svc-blue/tasks":
+			render.New().JSON(rw, http.StatusOK, tasks(blue, blueAddr))
+		case "/v2/apps/svc-green/tasks":
+			render.New().JSON(rw, http.StatusOK, tasks(green, greenAddr))
+		case "/v2/events":
+			rw.Header().Set("Content-Type", "text/event-stream")
+			rw.WriteHeader(http.StatusOK)
+			rw.(http.Flusher).Flush()
+
+			time.Sleep(500 * time.Millisecond)
+			atomic.StoreInt32(&deployed, 1)
+
+			fmt.Fprintf(rw, "event: %s\ndata: {\"eventType\":\"%s\",\"appDefinition\":{\"id\":\"%s\",\"labels\":{\"RESOLVER_0_NAME\":\"%s\"}}}\n\n",
+				marathon.EventAPIPost, marathon.EventAPIPost, green.ID, val)
+			rw.(http.Flusher).Flush()
+
+			select {
+			case <-rq.Context().Done():
+			case <-time.After(5 * time.Second):
+			}
+		}
+	})
+
+	ts := httptest.NewServer(handler)
+	defer ts.Close()
+
+	marathonClient := marathon.NewClient(&marathon.Config{
+		URI: ts.URL,
+	})
+
+	poller, err := newPoll(val, marathonClient)
+	assert.NoError(err, "an unexpected error occured in poller instantiation")
+	defer poller.Close()
+
+	poller.run()
+
+	ups, err := poller.Next()
+	assert.NoError(err, "an unexpected error occured in poller next")
+
+	assert.Equal(1, len(ups), "The number of updates should be 1")
+	assert.Equal(naming.Add, ups[0].Op, "the operations should be equals")
+	assert.Equal(blueAddr, ups[0].Addr, "the addresses should be equals")
+	assert.Equal(&Metadata{AppID: blue.ID, Weight: 1}, ups[0].Metadata, "the metadata should be equals")
+
+	ups, err = poller.Next()
+	assert.NoError(err, "an unexpected error occured in poller next")
+
+	assert.Equal(1, len(ups), "The number of updates should be 1")
+	assert.Equal(naming.Add, ups[0].Op, "the operations should be equals")
+	assert.Equal(greenAddr, ups[0].Addr, "the addresses should be equals")
+	assert.Equal(&Metadata{AppID: green.ID, Weight: 1}, ups[0].Metadata, "the metadata should be equals")
+}
+
+func TestPollRediscoverOnRelatedChanges(t *testing.T) {
+	assert := assert.New(t)
+
+	val := "service-test"
+
+	var discoveries, before int32
+	sent := make(chan struct{})
+
+	handler := http.HandlerFunc(func(rw http.ResponseWriter, rq *http.Request) {
+		switch rq.URL.Path {
+		case "/v2/apps":
+			atomic.AddInt32(&discoveries, 1)
+			render.New().JSON(rw, http.StatusOK, []*marathon.Application{
+				{ID: "/test", Labels: &map[string]string{"RESOLVER_0_NAME": val}},
+			})
+		case "/v2/apps/test/tasks":
+			render.New().JSON(rw, http.StatusOK, map[string]interface{}{"tasks": []*marathon.Task{}})
+		case "/v2/events":
+			rw.Header().Set("Content-Type", "text/event-stream")
+			rw.WriteHeader(http.StatusOK)
+			rw.(http.Flusher).Flush()
+
+			// The stream opening is caught up on first.
+			time.Sleep(500 * time.Millisecond)
+			atomic.StoreInt32(&before, atomic.LoadInt32(&discoveries))
+
+			// The unrelated changes are ignored.
+			for _, data := range []string{
+				`{"eventType":"api_post_event","appDefinition":{"id":"/other"}}`,
+				`{"eventType":"app_terminated_event","appId":"/other"}`,
+				`{"eventType":"deployment_success","plan":{"id":"1","steps":[{"actions":[{"action":"ScaleApplication","app":"/other"}]}]}}`,
+				`{"eventType":"pod_created_event","uri":"/v2/pods/other"}`,
+			} {
+				fmt.Fprintf(rw, "data: %s\n\n", data)
+			}
+			rw.(http.Flusher).Flush()
+
+			time.Sleep(rediscoverDelay + 200*time.Millisecond)
+
+			// A burst causes a single rediscovery.
+			for _, data := range []string{
+				`{"eventType":"api_post_event","appDefinition":{"id":"/test"}}`,
+				`{"eventType":"api_post_event","appDefinition":{"id":"/new","labels":{"RESOLVER_0_NAME":"service-test"}}}`,
+				`{"eventType":"deployment_success","plan":{"id":"2","steps":[{"actions":[{"action":"RestartApplication","app":"/test"}]}]}}`,
+			} {
+				fmt.Fprintf(rw, "data: %s\n\n", data)
+			}
+			rw.(http.Flusher).Flush()
+			close(sent)
+
+			<-rq.Context().Done()
+		default:
+			rw.WriteHeader(http.StatusNotFound)
+		}
+	})
+
+	ts := httptest.NewServer(handler)
+	defer ts.Close()
+
+	marathonClient := marathon.NewClient(&marathon.Config{
+		URI: ts.URL,
+	})
+
+	poller, err := newPoll(val, marathonClient, WithLogger(&logger{}))
+	assert.NoError(err, "an unexpected error occured in poller instantiation")
+	defer poller.Close()
+
+	poller.run()
+
+	// The updates are read, none being announced.
+	go poller.Next()
+
+	select {
+	case <-sent:
+	case <-time.After(5 * time.Second):
+		t.Fatal("the events weren't sent")
+	}
+
+	time.Sleep(rediscoverDelay + 500*time.Millisecond)
+
+	assert.Equal(int32(1), atomic.LoadInt32(&discoveries)-atomic.LoadInt32(&before), "the applications should be discovered once")
+}
+
+func TestPollNextOnPortIndexChanged(t *testing.T) {
+	assert := assert.New(t)
+
+	grpcServer, addr, err := newGRPCServer()
+	assert.NoError(err, "an unexpected error occured in grpc server instantiation")
+
+	defer grpcServer.Stop()
+
+	otherServer, otherAddr, err := newGRPCServer()
+	assert.NoError(err, "an unexpected error occured in grpc server instantiation")
+
+	defer otherServer.Stop()
+
+	val := "service-test"
+
+	port, _ := strconv.Atoi(strings.Split(addr, ":")[1])
+	otherPort, _ := strconv.Atoi(strings.Split(otherAddr, ":")[1])
+
+	tasks := []*marathon.Task{
+		{
+			ID:    uuid.Must(uuid.NewV4()).String(),
+			AppID: "/test",
+			Host:  "127.0.0.1",
+			Ports: []int{port, otherPort},
+		},
+	}
+
+	var moved int32
+
+	handler := http.HandlerFunc(func(rw http.ResponseWriter, rq *http.Request) {
+		switch rq.URL.Path {
+		case "/v2/apps":
+			key := "RESOLVER_0_NAME"
+			if atomic.LoadInt32(&moved) == 1 {
+				key = "RESOLVER_1_NAME"
+			}
+			render.New().JSON(rw, http.StatusOK, []*marathon.Application{
+				{ID: "/test", Labels: &map[string]string{key: val}},
+			})
+		case "/v2/apps/test/tasks":
+			render.New().JSON(rw, http.StatusOK, map[string]interface{}{"tasks": tasks})
+		default:
+			rw.WriteHeader(http.StatusNotFound)
+		}
+	})
+
+	ts := httptest.NewServer(handler)
+	defer ts.Close()
+
+	marathonClient := marathon.NewClient(&marathon.Config{
+		URI: ts.URL,
+	})
+
+	poller, err := newPoll(val, marathonClient,
+		WithPollInterval(50*time.Millisecond, 0),
+		WithDiscoveryInterval(100*time.Millisecond),
+	)
+	assert.NoError(err, "an unexpected error occured in poller instantiation")
+	defer poller.Close()
+
+	poller.run()
+
+	ups, err := poller.Next()
+	assert.NoError(err, "an unexpected error occured in poller next")
+
+	assert.Equal(1, len(ups), "The number of updates should be 1")
+	assert.Equal(naming.Add, ups[0].Op, "the operations should be equals")
+	assert.Equal(addr, ups[0].Addr, "the addresses should be equals")
+
+	atomic.StoreInt32(&moved, 1)
+
+	ups, err = poller.Next()
+	assert.NoError(err, "an unexpected error occured in poller next")
+
+	assert.Equal(1, len(ups), "The number of updates should be 1")
+	assert.Equal(naming.Delete, ups[0].Op, "the operations should be equals")
+	assert.Equal(addr, ups[0].Addr, "the addresses should be equals")
+
+	ups, err = poller.Next()
+	assert.NoError(err, "an unexpected error occured in poller next")
+
+	assert.Equal(1, len(ups), "The number of updates should be 1")
+	assert.Equal(naming.Add, ups[0].Op, "the operations should be equals")
+	assert.Equal(otherAddr, ups[0].Addr, "the addresses should be equals")
+}
+
+func TestPollNextOnApplicationRecreated(t *testing.T) {
+	assert := assert.New(t)
+
+	grpcServer, addr, err := newGRPCServer()
+	assert.NoError(err, "an unexpected error occured in grpc server instantiation")
+
+	defer grpcServer.Stop()
+
+	val := "service-test"
+
+	port, _ := strconv.Atoi(strings.Split(addr, ":")[1])
+
+	tasks := func(appID string) map[string]interface{} {
+		return map[string]interface{}{
+			"tasks": []*marathon.Task{
+				{
+					ID:    uuid.Must(uuid.NewV4()).String(),
+					AppID: appID,
+					Host:  "127.0.0.1",
+					Ports: []int{port},
+				},
+			},
+		}
+	}
+
+	var recreated int32
+
+	handler := http.HandlerFunc(func(rw http.ResponseWriter, rq *http.Request) {
+		id := "/test-v1"
+		if atomic.LoadInt32(&recreated) == 1 {
+			id = "/test-v2"
+		}
+
+		switch rq.URL.Path {
+		case "/v2/apps":
+			render.New().JSON(rw, http.StatusOK, []*marathon.Application{
+				{ID: id, Labels: &map[string]string{"RESOLVER_0_NAME": val}},
+			})
+		case "/v2/apps" + id + "/tasks":
+			render.New().JSON(rw, http.StatusOK, tasks(id))
+		default:
+			render.New().JSON(rw, http.StatusNotFound, map[string]string{"message": "not found"})
+		}
+	})
+
+	ts := httptest.NewServer(handler)
+	defer ts.Close()
+
+	marathonClient := marathon.NewClient(&marathon.Config{
+		URI: ts.URL,
+	})
+
+	logger := &logger{}
+
+	poller, err := newPoll(val, marathonClient,
+		WithPollInterval(50*time.Millisecond, 0),
+		WithDiscoveryInterval(0),
+		WithLogger(logger),
+	)
+	assert.NoError(err, "an unexpected error occured in poller instantiation")
+	defer poller.Close()
+
+	poller.run()
+
+	ups, err := poller.Next()
+	assert.NoError(err, "an unexpected error occured in poller next")
+
+	assert.Equal(1, len(ups), "The number of updates should be 1")
+	assert.Equal(&Metadata{AppID: "/test-v1", Weight: 1}, ups[0].Metadata, "the metadata should be equals")
+
+	atomic.StoreInt32(&recreated, 1)
+
+	// The address is still resolved, only its
+	// application has changed: no update is
+	// emitted and the connection is kept.
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		_, _ = poller.Next()
+	}()
+
+	select {
+	case <-done:
+		t.Fatal("no update was expected")
+	case <-time.After(500 * time.Millisecond):
+	}
+
+	assert.True(logger.contains("application /test-v2 added to service-test"), "the new application should be discovered")
+	assert.True(logger.contains("application /test-v1 removed from service-test"), "the old application should be removed")
+}
+
+func TestPollNextOnWeightChanged(t *testing.T) {
+	assert := assert.New(t)
+
+	grpcServer, addr, err := newGRPCServer()
+	assert.NoError(err, "an unexpected error occured in grpc server instantiation")
+
+	defer grpcServer.Stop()
+
+	val := "service-test"
+
+	port, _ := strconv.Atoi(strings.Split(addr, ":")[1])
+
+	version := "2019-01-01T00:00:00.000Z"
+
+	tasks := []*marathon.Task{
+		{
+			ID:      uuid.Must(uuid.NewV4()).String(),
+			AppID:   "/test",
+			Host:    "127.0.0.1",
+			Ports:   []int{port},
+			Version: version,
+		},
+	}
+
+	var shifted int32
+
+	handler := http.HandlerFunc(func(rw http.ResponseWriter, rq *http.Request) {
+		switch rq.URL.Path {
+		case "/v2/apps":
+			labels := map[string]string{"RESOLVER_0_NAME": val, "RESOLVER_0_WEIGHT": "3"}
+			if atomic.LoadInt32(&shifted) == 1 {
+				labels["RESOLVER_0_WEIGHT_"+version] = "1"
+			}
+			render.New().JSON(rw, http.StatusOK, []*marathon.Application{{ID: "/test", Labels: &labels}})
+		case "/v2/apps/test/tasks":
+			render.New().JSON(rw, http.StatusOK, map[string]interface{}{"tasks": tasks})
+		default:
+			rw.WriteHeader(http.StatusNotFound)
+		}
+	})
+
+	ts := httptest.NewServer(handler)
+	defer ts.Close()
+
+	marathonClient := marathon.NewClient(&marathon.Config{
+		URI: ts.URL,
+	})
+
+	poller, err := newPoll(val, marathonClient,
+		WithPollInterval(50*time.Millisecond, 0),
+		WithDiscoveryInterval(100*time.Millisecond),
+	)
+	assert.NoError(err, "an unexpected error occured in poller instantiation")
+	defer poller.Close()
+
+	poller.run()
+
+	ups, err := poller.Next()
+	assert.NoError(err, "an unexpected error occured in poller next")
+
+	assert.Equal(1, len(ups), "The number of updates should be 1")
+	assert.Equal(&Metadata{AppID: "/test", Weight: 3}, ups[0].Metadata, "the metadata should be equals")
+
+	atomic.StoreInt32(&shifted, 1)
+
+	ups, err = poller.Next()
+	assert.NoError(err, "an unexpected error occured in poller next")
+
+	assert.Equal(2, len(ups), "The number of updates should be 2")
+	assert.Equal(naming.Delete, ups[0].Op, "the operations should be equals")
+	assert.Equal(&Metadata{AppID: "/test", Weight: 3}, ups[0].Metadata, "the metadata should be equals")
+	assert.Equal(naming.Add, ups[1].Op, "the operations should be equals")
+	assert.Equal(addr, ups[1].Addr, "the addresses should be equals")
+	assert.Equal(&Metadata{AppID: "/test", Weight: 1}, ups[1].Metadata, "the metadata should be equals")
+}
+
+func TestPollNextWithLocality(t *testing.T) {
+	assert := assert.New(t)
+
+	val := "service-test"
+
+	local, remote := "10.0.0.1:8080", "10.0.0.2:8080"
+
+	tasks := []*marathon.Task{
+		{ID: "test.1", AppID: "/test", Host: "10.0.0.1", Ports: []int{8080}, SlaveID: "agent-a"},
+		{ID: "test.2", AppID: "/test", Host: "10.0.0.2", Ports: []int{8080}, SlaveID: "agent-b"},
+	}
+
+	agents := map[string]map[string]interface{}{
+		"agent-a": {"id": "agent-a", "attributes": map[string]string{"zone": "a", "rack": "r1"}},
+		"agent-b": {"id": "agent-b", "attributes": map[string]string{"zone": "b", "rack": "r2"}},
+	}
+
+	handler := http.HandlerFunc(func(rw http.ResponseWriter, rq *http.Request) {
+		switch rq.URL.Path {
+		case "/v2/apps":
+			render.New().JSON(rw, http.StatusOK, []*marathon.Application{
+				{ID: "/test", Labels: &map[string]string{"RESOLVER_0_NAME": val}},
+			})
+		case "/v2/apps/test/tasks":
+			render.New().JSON(rw, http.StatusOK, map[string]interface{}{"tasks": tasks})
+		case "/slaves":
+			render.New().JSON(rw, http.StatusOK, map[string]interface{}{
+				"slaves": []interface{}{agents["agent-a"], agents["agent-b"]},
+			})
+		default:
+			rw.WriteHeader(http.StatusNotFound)
+		}
+	})
+
+	ts := httptest.NewServer(handler)
+	defer ts.Close()
+
+	marathonClient := marathon.NewClient(&marathon.Config{
+		URI:      ts.URL,
+		MesosURI: ts.URL,
+	})
+
+	// The agents are cached beforehand for the
+	// first update to be located.
+	_, err := marathonClient.Agents()
+	assert.NoError(err, "an unexpected error occured in agents")
+
+	l := &logger{}
+	prober := newManualProber()
+
+	poller, err := newPoll(val, marathonClient,
+		WithMesos(ts.URL),
+		WithLocality("a", 0.5),
+		WithProber(prober),
+		WithPollInterval(50*time.Millisecond, 0),
+		WithLogger(l),
+	)
+	assert.NoError(err, "an unexpected error occured in poller instantiation")
+	defer poller.Close()
+
+	poller.run()
+
+	// Without local backend, the remote ones are resolved.
+	go prober.set(remote, true)
+
+	ups, err := poller.Next()
+	assert.NoError(err, "an unexpected error occured in poller next")
+
+	assert.Equal(1, len(ups), "The number of updates should be 1")
+	assert.Equal(naming.Add, ups[0].Op, "the operations should be equals")
+	assert.Equal(remote, ups[0].Addr, "the addresses should be equals")
+	assert.Equal(&Metadata{AppID: "/test", Weight: 1, Zone: "b", Rack: "r2"}, ups[0].Metadata, "the metadata should be equals")
+
+	go prober.set(local, true)
+
+	ups, err = poller.Next()
+	assert.NoError(err, "an unexpected error occured in poller next")
+
+	assert.Equal(2, len(ups), "The number of updates should be 2")
+	assert.Equal(&naming.Update{Op: naming.Delete, Addr: remote, Metadata: &Metadata{AppID: "/test", Weight: 1, Zone: "b", Rack: "r2"}}, ups[0], "the updates should be equals")
+	assert.Equal(&naming.Update{Op: naming.Add, Addr: local, Metadata: &Metadata{AppID: "/test", Weight: 1, Zone: "a", Rack: "r1"}}, ups[1], "the updates should be equals")
+
+	// The local zone fails over below the threshold.
+	go prober.set(local, false)
+
+	ups, err = poller.Next()
+	assert.NoError(err, "an unexpected error occured in poller next")
+
+	assert.Equal(2, len(ups), "The number of updates should be 2")
+	assert.Equal(naming.Delete, ups[0].Op, "the operations should be equals")
+	assert.Equal(local, ups[0].Addr, "the addresses should be equals")
+	assert.Equal(naming.Add, ups[1].Op, "the operations should be equals")
+	assert.Equal(remote, ups[1].Addr, "the addresses should be equals")
+
+	assert.True(l.contains("failing over"), "the fail over should be reported to the logger")
+}
+
+func TestPollNextWithPanicThreshold(t *testing.T) {
+	assert := assert.New(t)
+
+	val := "service-panic"
+
+	addrs := []string{"10.0.0.1:8080", "10.0.0.2:8080", "10.0.0.3:8080"}
+
+	tasks := []*marathon.Task{
+		{ID: "test.1", AppID: "/test", Host: "10.0.0.1", Ports: []int{8080}},
+		{ID: "test.2", AppID: "/test", Host: "10.0.0.2", Ports: []int{8080}},
+		{ID: "test.3", AppID: "/test", Host: "10.0.0.3", Ports: []int{8080}},
+	}
+
+	handler := http.HandlerFunc(func(rw http.ResponseWriter, rq *http.Request) {
+		switch rq.URL.Path {
+		case "/v2/apps":
+			render.New().JSON(rw, http.StatusOK, []*marathon.Application{
+				{ID: "/test", Labels: &map[string]string{"RESOLVER_0_NAME": val}},
+			})
+		case "/v2/apps/test/tasks":
+			render.New().JSON(rw, http.StatusOK, map[string]interface{}{"tasks": tasks})
+		default:
+			rw.WriteHeader(http.StatusNotFound)
+		}
+	})
+
+	ts := httptest.NewServer(handler)
+	defer ts.Close()
+
+	marathonClient := marathon.NewClient(&marathon.Config{
+		URI: ts.URL,
+	})
+
+	l := &logger{}
+	r := &recorder{}
+	prober := newManualProber()
+
+	poller, err := newPoll(val, marathonClient,
+		WithPanicThreshold(0.5),
+		WithProber(prober),
+		WithPollInterval(50*time.Millisecond, 0),
+		WithLogger(l),
+		WithMetrics(r),
+	)
+	assert.NoError(err, "an unexpected error occured in poller instantiation")
+	defer poller.Close()
+
+	poller.run()
+
+	for _, addr := range addrs {
+		go prober.set(addr, true)
+
+		ups, err := poller.Next()
+		assert.NoError(err, "an unexpected error occured in poller next")
+
+		assert.Equal(1, len(ups), "The number of updates should be 1")
+		assert.Equal(naming.Add, ups[0].Op, "the operations should be equals")
+		assert.Equal(addr, ups[0].Addr, "the addresses should be equals")
+	}
+
+	go prober.set(addrs[0], false)
+
+	ups, err := poller.Next()
+	assert.NoError(err, "an unexpected error occured in poller next")
+
+	assert.Equal(1, len(ups), "The number of updates should be 1")
+	assert.Equal(naming.Delete, ups[0].Op, "the operations should be equals")
+	assert.Equal(addrs[0], ups[0].Addr, "the addresses should be equals")
+
+	// Below the threshold, every backend is resolved.
+	go prober.set(addrs[1], false)
+
+	ups, err = poller.Next()
+	assert.NoError(err, "an unexpected error occured in poller next")
+
+	assert.Equal(1, len(ups), "The number of updates should be 1")
+	assert.Equal(naming.Add, ups[0].Op, "the operations should be equals")
+	assert.Equal(addrs[0], ups[0].Addr, "the addresses should be equals")
+
+	assert.True(l.contains("entering panic mode"), "the panic should be reported to the logger")
+	assert.True(r.contains("panic "+val+" true"), "the panic should be reported to the metrics")
+
+	go prober.set(addrs[0], true)
+
+	ups, err = poller.Next()
+	assert.NoError(err, "an unexpected error occured in poller next")
+
+	assert.Equal(1, len(ups), "The number of updates should be 1")
+	assert.Equal(naming.Delete, ups[0].Op, "the operations should be equals")
+	assert.Equal(addrs[1], ups[0].Addr, "the addresses should be equals")
+
+	assert.True(l.contains("leaving panic mode"), "the recovery should be reported to the logger")
+	assert.True(r.contains("panic "+val+" false"), "the recovery should be reported to the metrics")
+}
+
+func TestPollNextWithPanicThresholdOnTruncatedTasks(t *testing.T) {
+	assert := assert.New(t)
+
+	val := "service-test"
+
+	addrs := []string{"10.0.0.1:8080", "10.0.0.2:8080"}
+
+	tasks := []*marathon.Task{
+		{ID: "test.1", AppID: "/test", Host: "10.0.0.1", Ports: []int{8080}},
+		{ID: "test.2", AppID: "/test", Host: "10.0.0.2", Ports: []int{8080}},
+	}
+
+	var requests int32
+
+	handler := http.HandlerFunc(func(rw http.ResponseWriter, rq *http.Request) {
+		switch rq.URL.Path {
+		case "/v2/apps":
+			render.New().JSON(rw, http.StatusOK, []*marathon.Application{
+				{ID: "/test", Labels: &map[string]string{"RESOLVER_0_NAME": val}},
+			})
+		case "/v2/apps/test/tasks":
+			// The task lists are truncated for a while.
+			if n := atomic.AddInt32(&requests, 1); n >= 2 && n <= 5 {
+				render.New().JSON(rw, http.StatusOK, map[string]interface{}{"tasks": []*marathon.Task{}})
+				return
+			}
+			render.New().JSON(rw, http.StatusOK, map[string]interface{}{"tasks": tasks})
+		default:
+			rw.WriteHeader(http.StatusNotFound)
+		}
+	})
+
+	ts := httptest.NewServer(handler)
+	defer ts.Close()
+
+	marathonClient := marathon.NewClient(&marathon.Config{
+		URI: ts.URL,
+	})
+
+	prober := newManualProber()
+
+	poller, err := newPoll(val, marathonClient,
+		WithPanicThreshold(0.5),
+		WithProber(prober),
+		WithPollInterval(50*time.Millisecond, 0),
+		WithLogger(&logger{}),
+	)
+	assert.NoError(err, "an unexpected error occured in poller instantiation")
+	defer poller.Close()
+
+	poller.run()
+
+	for _, addr := range addrs {
+		go prober.set(addr, true)
+
+		ups, err := poller.Next()
+		assert.NoError(err, "an unexpected error occured in poller next")
+
+		assert.Equal(1, len(ups), "The number of updates should be 1")
+		assert.Equal(naming.Add, ups[0].Op, "the operations should be equals")
+	}
+
+	go func() {
+		for atomic.LoadInt32(&requests) < 7 {
+			time.Sleep(10 * time.Millisecond)
+		}
+
+		prober.set(addrs[0], false)
+	}()
+
+	// The backends are kept across the truncated task lists.
+	ups, err := poller.Next()
+	assert.NoError(err, "an unexpected error occured in poller next")
+
+	assert.Equal(1, len(ups), "The number of updates should be 1")
+	assert.Equal(naming.Delete, ups[0].Op, "the operations should be equals")
+	assert.Equal(addrs[0], ups[0].Addr, "the addresses should be equals")
+}
+
+func TestPollNextFromSnapshot(t *testing.T) {
+	assert := assert.New(t)
+
+	dir, err := ioutil.TempDir("", "snapshot")
+	assert.NoError(err, "an unexpected error occured in temp dir creation")
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "snapshot.json")
+
+	val := "service-test"
+	addr := "10.0.0.1:8080"
+
+	err = newSnapshot(path).save(val, map[string]*Metadata{addr: {AppID: "/test", Weight: 1}})
+	assert.NoError(err, "an unexpected error occured in snapshot save")
+
+	var reachable int32
+
+	handler := http.HandlerFunc(func(rw http.ResponseWriter, rq *http.Request) {
+		if atomic.LoadInt32(&reachable) == 0 {
+			rw.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+
+		switch rq.URL.Path {
+		case "/v2/apps":
+			render.New().JSON(rw, http.StatusOK, []*marathon.Application{
+				{ID: "/test", Labels: &map[string]string{"RESOLVER_0_NAME": val}},
+			})
+		case "/v2/apps/test/tasks":
+			render.New().JSON(rw, http.StatusOK, map[string]interface{}{
+				"tasks": []*marathon.Task{{ID: "test.1", AppID: "/test", Host: "10.0.0.1", Ports: []int{8080}}},
+			})
+		default:
+			rw.WriteHeader(http.StatusNotFound)
+		}
+	})
+
+	ts := httptest.NewServer(handler)
+	defer ts.Close()
+
+	marathonClient := marathon.NewClient(&marathon.Config{
+		URI: ts.URL,
+	})
+
+	_, err = newPoll(val, marathonClient)
+	assert.Error(err, "an error was expected without snapshot")
+
+	poller, err := newPoll(val, marathonClient,
+		WithSnapshot(path),
+		WithProber(newManualProber()),
+		WithPollInterval(50*time.Millisecond, 0),
+		WithLogger(&logger{}),
+	)
+	assert.NoError(err, "an unexpected error occured in poller instantiation")
+	defer poller.Close()
+
+	poller.run()
+
+	ups, err := poller.Next()
+	assert.NoError(err, "an unexpected error occured in poller next")
+
+	assert.Equal(1, len(ups), "The number of updates should be 1")
+	assert.Equal(&naming.Update{Op: naming.Add, Addr: addr, Metadata: &Metadata{AppID: "/test", Weight: 1, Stale: true}}, ups[0], "the updates should be equals")
+
+	atomic.StoreInt32(&reachable, 1)
+
+	// The address is announced again once fresh.
+	ups, err = poller.Next()
+	assert.NoError(err, "an unexpected error occured in poller next")
+
+	assert.Equal(2, len(ups), "The number of updates should be 2")
+	assert.Equal(&naming.Update{Op: naming.Delete, Addr: addr, Metadata: &Metadata{AppID: "/test", Weight: 1, Stale: true}}, ups[0], "the updates should be equals")
+	assert.Equal(&naming.Update{Op: naming.Add, Addr: addr, Metadata: &Metadata{AppID: "/test", Weight: 1}}, ups[1], "the updates should be equals")
+}
+
+func TestPollNextWithStaticFile(t *testing.T) {
+	assert := assert.New(t)
+
+	dir, err := ioutil.TempDir("", "static")
+	assert.NoError(err, "an unexpected error occured in temp dir creation")
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "static.json")
+
+	err = ioutil.WriteFile(path, []byte(`{"service-test": {"addresses": ["127.0.0.1:8080"]}}`), 0644)
+	assert.NoError(err, "an unexpected error occured in file write")
+
+	handler := http.HandlerFunc(func(rw http.ResponseWriter, rq *http.Request) {
+		switch rq.URL.Path {
+		case "/v2/apps":
+			render.New().JSON(rw, http.StatusOK, []*marathon.Application{})
+		default:
+			rw.WriteHeader(http.StatusNotFound)
+		}
+	})
+
+	ts := httptest.NewServer(handler)
+	defer ts.Close()
+
+	marathonClient := marathon.NewClient(&marathon.Config{
+		URI: ts.URL,
+	})
+
+	prober := newManualProber()
+
+	poller, err := newPoll("service-test", marathonClient,
+		WithStaticFile(path, 50*time.Millisecond),
+		WithProber(prober),
+		WithLogger(&logger{}),
+	)
+	assert.NoError(err, "an unexpected error occured in poller instantiation")
+	defer poller.Close()
+
+	poller.run()
+
+	go prober.set("127.0.0.1:8080", true)
+
+	ups, err := poller.Next()
+	assert.NoError(err, "an unexpected error occured in poller next")
+
+	assert.Equal(1, len(ups), "The number of updates should be 1")
+	assert.Equal(&naming.Update{Op: naming.Add, Addr: "127.0.0.1:8080", Metadata: &Metadata{Weight: 1}}, ups[0], "the updates should be equals")
+
+	// The file is reloaded on change.
+	err = ioutil.WriteFile(path, []byte(`{"service-test": {"addresses": ["127.0.0.1:9090"]}}`), 0644)
+	assert.NoError(err, "an unexpected error occured in file write")
+
+	modTime := time.Now().Add(time.Minute)
+	assert.NoError(os.Chtimes(path, modTime, modTime), "an unexpected error occured in file times change")
+
+	ups, err = poller.Next()
+	assert.NoError(err, "an unexpected error occured in poller next")
+
+	assert.Equal(1, len(ups), "The number of updates should be 1")
+	assert.Equal(naming.Delete, ups[0].Op, "the operations should be equals")
+	assert.Equal("127.0.0.1:8080", ups[0].Addr, "the addresses should be equals")
+
+	go prober.set("127.0.0.1:9090", true)
+
+	ups, err = poller.Next()
+	assert.NoError(err, "an unexpected error occured in poller next")
+
+	assert.Equal(1, len(ups), "The number of updates should be 1")
+	assert.Equal(naming.Add, ups[0].Op, "the operations should be equals")
+	assert.Equal("127.0.0.1:9090", ups[0].Addr, "the addresses should be equals")
+}
+
+func TestPollNextWithStaticFallback(t *testing.T) {
+	assert := assert.New(t)
+
+	val := "service-test"
+
+	var scaled int32
+
+	handler := http.HandlerFunc(func(rw http.ResponseWriter, rq *http.Request) {
+		switch rq.URL.Path {
+		case "/v2/apps":
+			render.New().JSON(rw, http.StatusOK, []*marathon.Application{
+				{ID: "/test", Labels: &map[string]string{"RESOLVER_0_NAME": val}},
+			})
+		case "/v2/apps/test/tasks":
+			tasks := []*marathon.Task{}
+			if atomic.LoadInt32(&scaled) == 1 {
+				tasks = append(tasks, &marathon.Task{ID: "test.1", AppID: "/test", Host: "10.0.0.1", Ports: []int{8080}})
+			}
+			render.New().JSON(rw, http.StatusOK, map[string]interface{}{"tasks": tasks})
+		default:
+			rw.WriteHeader(http.StatusNotFound)
+		}
+	})
+
+	ts := httptest.NewServer(handler)
+	defer ts.Close()
+
+	marathonClient := marathon.NewClient(&marathon.Config{
+		URI: ts.URL,
+	})
+
+	prober := newManualProber()
+
+	poller, err := newPoll(val, marathonClient,
+		WithStatic(map[string]StaticEntry{
+			val: {Mode: StaticFallback, Addresses: []string{"127.0.0.1:8080"}},
+		}),
+		WithProber(prober),
+		WithPollInterval(50*time.Millisecond, 0),
+		WithLogger(&logger{}),
+	)
+	assert.NoError(err, "an unexpected error occured in poller instantiation")
+	defer poller.Close()
+
+	poller.run()
+
+	// Marathon returns no address, the static one is resolved.
+	go prober.set("127.0.0.1:8080", true)
+
+	ups, err := poller.Next()
+	assert.NoError(err, "an unexpected error occured in poller next")
+
+	assert.Equal(1, len(ups), "The number of updates should be 1")
+	assert.Equal(naming.Add, ups[0].Op, "the operations should be equals")
+	assert.Equal("127.0.0.1:8080", ups[0].Addr, "the addresses should be equals")
+
+	atomic.StoreInt32(&scaled, 1)
+
+	ups, err = poller.Next()
+	assert.NoError(err, "an unexpected error occured in poller next")
+
+	assert.Equal(1, len(ups), "The number of updates should be 1")
+	assert.Equal(naming.Delete, ups[0].Op, "the operations should be equals")
+	assert.Equal("127.0.0.1:8080", ups[0].Addr, "the addresses should be equals")
+
+	go prober.set("10.0.0.1:8080", true)
+
+	ups, err = poller.Next()
+	assert.NoError(err, "an unexpected error occured in poller next")
+
+	assert.Equal(1, len(ups), "The number of updates should be 1")
+	assert.Equal(naming.Add, ups[0].Op, "the operations should be equals")
+	assert.Equal("10.0.0.1:8080", ups[0].Addr, "the addresses should be equals")
+}
+
+func TestPollInstantiationWithErrorOnStaticMode(t *testing.T) {
+	assert := assert.New(t)
+
+	marathonClient := marathon.NewClient(&marathon.Config{
+		URI: "http://marathon",
+	})
+
+	_, err := newPoll("service-test", marathonClient, WithStatic(map[string]StaticEntry{
+		"service-test": {Mode: "unknown"},
+	}))
+	assert.Error(err, "an error was expected on static mode")
+}
+
+func TestPollNextWithPanicThresholdOnScaleDown(t *testing.T) {
+	assert := assert.New(t)
+
+	val := "service-test"
+
+	addrs := []string{"10.0.0.1:8080", "10.0.0.2:8080", "10.0.0.3:8080"}
+
+	tasks := []*marathon.Task{
+		{ID: "test.1", AppID: "/test", Host: "10.0.0.1", Ports: []int{8080}},
+		{ID: "test.2", AppID: "/test", Host: "10.0.0.2", Ports: []int{8080}},
+		{ID: "test.3", AppID: "/test", Host: "10.0.0.3", Ports: []int{8080}},
+	}
+
+	var scaled int32
+
+	handler := http.HandlerFunc(func(rw http.ResponseWriter, rq *http.Request) {
+		switch rq.URL.Path {
+		case "/v2/apps":
+			render.New().JSON(rw, http.StatusOK, []*marathon.Application{
+				{ID: "/test", Labels: &map[string]string{"RESOLVER_0_NAME": val}},
+			})
+		case "/v2/apps/test/tasks":
+			if atomic.LoadInt32(&scaled) == 1 {
+				render.New().JSON(rw, http.StatusOK, map[string]interface{}{"tasks": tasks[:1]})
+				return
+			}
+			render.New().JSON(rw, http.StatusOK, map[string]interface{}{"tasks": tasks})
+		default:
+			rw.WriteHeader(http.StatusNotFound)
+		}
+	})
+
+	ts := httptest.NewServer(handler)
+	defer ts.Close()
+
+	marathonClient := marathon.NewClient(&marathon.Config{
+		URI: ts.URL,
+	})
+
+	prober := newManualProber()
+
+	poller, err := newPoll(val, marathonClient,
+		WithPanicThreshold(0.5),
+		WithProber(prober),
+		WithPollInterval(50*time.Millisecond, 0),
+		WithLogger(&logger{}),
+	)
+	assert.NoError(err, "an unexpected error occured in poller instantiation")
+	defer poller.Close()
+
+	poller.run()
+
+	for _, addr := range addrs {
+		go prober.set(addr, true)
+
+		_, err := poller.Next()
+		assert.NoError(err, "an unexpected error occured in poller next")
+	}
+
+	// A scale down isn't a panic, the backends are removed.
+	atomic.StoreInt32(&scaled, 1)
+
+	ups, err := poller.Next()
+	assert.NoError(err, "an unexpected error occured in poller next")
+
+	assert.Equal(2, len(ups), "The number of updates should be 2")
+	assert.Equal(naming.Delete, ups[0].Op, "the operations should be equals")
+	assert.Equal(addrs[1], ups[0].Addr, "the addresses should be equals")
+	assert.Equal(naming.Delete, ups[1].Op, "the operations should be equals")
+	assert.Equal(addrs[2], ups[1].Addr, "the addresses should be equals")
+}
+
+func TestPollNextWithPanicThresholdOnScaleToZero(t *testing.T) {
+	assert := assert.New(t)
+
+	val := "service-test"
+
+	addrs := []string{"10.0.0.1:8080", "10.0.0.2:8080"}
+
+	tasks := []*marathon.Task{
+		{ID: "test.1", AppID: "/test", Host: "10.0.0.1", Ports: []int{8080}},
+		{ID: "test.2", AppID: "/test", Host: "10.0.0.2", Ports: []int{8080}},
+	}
+
+	var scaled int32
+
+	handler := http.HandlerFunc(func(rw http.ResponseWriter, rq *http.Request) {
+		instances := 2
+		if atomic.LoadInt32(&scaled) == 1 {
+			instances = 0
+		}
+
+		switch rq.URL.Path {
+		case "/v2/apps":
+			render.New().JSON(rw, http.StatusOK, []*marathon.Application{
+				{ID: "/test", Instances: &instances, Labels: &map[string]string{"RESOLVER_0_NAME": val}},
+			})
+		case "/v2/apps/test/tasks":
+			render.New().JSON(rw, http.StatusOK, map[string]interface{}{"tasks": tasks[:instances]})
+		default:
+			rw.WriteHeader(http.StatusNotFound)
+		}
+	})
+
+	ts := httptest.NewServer(handler)
+	defer ts.Close()
+
+	marathonClient := marathon.NewClient(&marathon.Config{
+		URI: ts.URL,
+	})
+
+	prober := newManualProber()
+
+	l := &logger{}
+
+	poller, err := newPoll(val, marathonClient,
+		WithPanicThreshold(0.5),
+		WithProber(prober),
+		WithPollInterval(50*time.Millisecond, 0),
+		WithLogger(l),
+	)
+	assert.NoError(err, "an unexpected error occured in poller instantiation")
+	defer poller.Close()
+
+	poller.run()
+
+	for _, addr := range addrs {
+		go prober.set(addr, true)
+
+		_, err := poller.Next()
+		assert.NoError(err, "an unexpected error occured in poller next")
+	}
+
+	// A scale to zero isn't a panic, the backends are removed.
+	atomic.StoreInt32(&scaled, 1)
+
+	ups, err := poller.Next()
+	assert.NoError(err, "an unexpected error occured in poller next")
+
+	assert.Equal(2, len(ups), "The number of updates should be 2")
+	assert.Equal(naming.Delete, ups[0].Op, "the operations should be equals")
+	assert.Equal(naming.Delete, ups[1].Op, "the operations should be equals")
+
+	assert.False(l.contains("entering panic mode"), "the poller shouldn't be in panic")
+}
+
+func TestPollNextWithPanicThresholdOnApplicationDestroyed(t *testing.T) {
+	assert := assert.New(t)
+
+	val := "service-test"
+
+	tasks := []*marathon.Task{
+		{ID: "test.1", AppID: "/test", Host: "10.0.0.1", Ports: []int{8080}},
+	}
+
+	var destroyed int32
+
+	handler := http.HandlerFunc(func(rw http.ResponseWriter, rq *http.Request) {
+		if atomic.LoadInt32(&destroyed) == 1 {
+			switch rq.URL.Path {
+			case "/v2/apps":
+				render.New().JSON(rw, http.StatusOK, []*marathon.Application{})
+			default:
+				rw.WriteHeader(http.StatusNotFound)
+			}
+			return
+		}
+
+		switch rq.URL.Path {
+		case "/v2/apps":
+			render.New().JSON(rw, http.StatusOK, []*marathon.Application{
+				{ID: "/test", Labels: &map[string]string{"RESOLVER_0_NAME": val}},
+			})
+		case "/v2/apps/test/tasks":
+			render.New().JSON(rw, http.StatusOK, map[string]interface{}{"tasks": tasks})
+		default:
+			rw.WriteHeader(http.StatusNotFound)
+		}
+	})
+
+	ts := httptest.NewServer(handler)
+	defer ts.Close()
+
+	marathonClient := marathon.NewClient(&marathon.Config{
+		URI: ts.URL,
+	})
+
+	prober := newManualProber()
+
+	l := &logger{}
+
+	poller, err := newPoll(val, marathonClient,
+		WithPanicThreshold(0.5),
+		WithProber(prober),
+		WithPollInterval(50*time.Millisecond, 0),
+		WithLogger(l),
+	)
+	assert.NoError(err, "an unexpected error occured in poller instantiation")
+	defer poller.Close()
+
+	poller.run()
+
+	go prober.set("10.0.0.1:8080", true)
+
+	_, err = poller.Next()
+	assert.NoError(err, "an unexpected error occured in poller next")
+
+	// A destroyed application isn't a panic, the backends are removed.
+	atomic.StoreInt32(&destroyed, 1)
+
+	ups, err := poller.Next()
+	assert.NoError(err, "an unexpected error occured in poller next")
+
+	assert.Equal(1, len(ups), "The number of updates should be 1")
+	assert.Equal(naming.Delete, ups[0].Op, "the operations should be equals")
+	assert.Equal("10.0.0.1:8080", ups[0].Addr, "the addresses should be equals")
+
+	assert.False(l.contains("entering panic mode"), "the poller shouldn't be in panic")
+}
+
+// newAgentsServer returns a marathon and mesos server running a task on
+// agent-a, the agents lookups being answered by the given handler
+func newAgentsServer(val string, slaves http.HandlerFunc) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, rq *http.Request) {
+		switch rq.URL.Path {
+		case "/v2/apps":
+			render.New().JSON(rw, http.StatusOK, []*marathon.Application{
+				{ID: "/test", Labels: &map[string]string{"RESOLVER_0_NAME": val}},
+			})
+		case "/v2/apps/test/tasks":
+			render.New().JSON(rw, http.StatusOK, map[string]interface{}{
+				"tasks": []*marathon.Task{{ID: "test.1", AppID: "/test", Host: "10.0.0.1", Ports: []int{8080}, SlaveID: "agent-a"}},
+			})
+		case "/slaves":
+			slaves(rw, rq)
+		default:
+			rw.WriteHeader(http.StatusNotFound)
+		}
+	}))
+}
+
+func TestPollNextWithLocalityOnAgentsLookup(t *testing.T) {
+	assert := assert.New(t)
+
+	val := "service-test"
+	addr := "10.0.0.1:8080"
+
+	var slaves int32
+
+	ts := newAgentsServer(val, func(rw http.ResponseWriter, rq *http.Request) {
+		atomic.AddInt32(&slaves, 1)
+
+		// The agents are retrieved slowly.
+		time.Sleep(200 * time.Millisecond)
+
+		render.New().JSON(rw, http.StatusOK, map[string]interface{}{
+			"slaves": []interface{}{
+				map[string]interface{}{"id": "agent-a", "attributes": map[string]string{"zone": "a"}},
+			},
+		})
+	})
+	defer ts.Close()
+
+	marathonClient := marathon.NewClient(&marathon.Config{
+		URI:           ts.URL,
+		MesosURI:      ts.URL,
+		AgentCacheTTL: 200 * time.Millisecond,
+	})
+
+	prober := newManualProber()
+
+	poller, err := newPoll(val, marathonClient,
+		WithMesos(ts.URL),
+		WithLocality("a", 0.5),
+		WithProber(prober),
+		WithPollInterval(50*time.Millisecond, 0),
+		WithLogger(&logger{}),
+	)
+	assert.NoError(err, "an unexpected error occured in poller instantiation")
+	defer poller.Close()
+
+	poller.run()
+
+	go prober.set(addr, true)
+
+	// The target is announced once
+	// its agent is known only.
+	ups, err := poller.Next()
+	assert.NoError(err, "an unexpected error occured in poller next")
+
+	assert.Equal(1, len(ups), "The number of updates should be 1")
+	assert.Equal(&naming.Update{Op: naming.Add, Addr: addr, Metadata: &Metadata{AppID: "/test", Weight: 1, Zone: "a"}}, ups[0], "the updates should be equals")
+
+	// The agent expires, its locality
+	// being kept while it is retrieved.
+	next := make(chan []*naming.Update, 1)
+	go func() {
+		ups, _ := poller.Next()
+		next <- ups
+	}()
+
+	select {
+	case ups := <-next:
+		t.Fatalf("unexpected updates %v", ups)
+	case <-time.After(time.Second):
+	}
+
+	assert.Equal(int32(1), atomic.LoadInt32(&slaves), "the agents should be looked up once per interval")
+}
+
+func TestPollNextWithLocalityOnAgentsLookupFailure(t *testing.T) {
+	assert := assert.New(t)
+
+	val := "service-test"
+	addr := "10.0.0.1:8080"
+
+	ts := newAgentsServer(val, func(rw http.ResponseWriter, rq *http.Request) {
+		rw.WriteHeader(http.StatusInternalServerError)
+	})
+	defer ts.Close()
+
+	marathonClient := marathon.NewClient(&marathon.Config{
+		URI:      ts.URL,
+		MesosURI: ts.URL,
+	})
+
+	prober := newManualProber()
+
+	l := &logger{}
+
+	poller, err := newPoll(val, marathonClient,
+		WithMesos(ts.URL),
+		WithLocality("a", 0.5),
+		WithProber(prober),
+		WithPollInterval(50*time.Millisecond, 0),
+		WithLogger(l),
+	)
+	assert.NoError(err, "an unexpected error occured in poller instantiation")
+	defer poller.Close()
+
+	poller.run()
+
+	go prober.set(addr, true)
+
+	// The target is announced without
+	// locality once the lookup failed.
+	ups, err := poller.Next()
+	assert.NoError(err, "an unexpected error occured in poller next")
+
+	assert.Equal(1, len(ups), "The number of updates should be 1")
+	assert.Equal(&naming.Update{Op: naming.Add, Addr: addr, Metadata: &Metadata{AppID: "/test", Weight: 1}}, ups[0], "the updates should be equals")
+	assert.True(l.contains("couldn't retrieve the agents"), "the lookup failure should be logged")
+}
+
+func TestPollResyncWithUnrelatedEvents(t *testing.T) {
+	assert := assert.New(t)
+
+	val := "service-test"
+
+	var polls int32
+
+	handler := http.HandlerFunc(func(rw http.ResponseWriter, rq *http.Request) {
+		switch rq.URL.Path {
+		case "/v2/apps":
+			render.New().JSON(rw, http.StatusOK, []*marathon.Application{
+				{ID: "/test", Labels: &map[string]string{"RESOLVER_0_NAME": val}},
+			})
+		case "/v2/apps/test/tasks":
+			atomic.AddInt32(&polls, 1)
+			render.New().JSON(rw, http.StatusOK, map[string]interface{}{"tasks": []*marathon.Task{}})
+		case "/v2/events":
+			rw.Header().Set("Content-Type", "text/event-stream")
+			rw.WriteHeader(http.StatusOK)
+			rw.(http.Flusher).Flush()
+
+			// A steady stream of events of another application.
+			for {
+				select {
+				case <-rq.Context().Done():
+					return
+				case <-time.After(20 * time.Millisecond):
+				}
+
+				fmt.Fprintf(rw, "event: %s\ndata: {\"eventType\":\"%s\",\"appId\":\"/other\"}\n\n",
+					marathon.EventStatusUpdate, marathon.EventStatusUpdate)
+				rw.(http.Flusher).Flush()
+			}
+		}
+	})
+
+	ts := httptest.NewServer(handler)
+	defer ts.Close()
+
+	marathonClient := marathon.NewClient(&marathon.Config{
+		URI: ts.URL,
+	})
+
+	poller, err := newPoll(val, marathonClient, WithLogger(&logger{}), func(o *options) {
+		o.resyncInterval = 100 * time.Millisecond
+	})
+	assert.NoError(err, "an unexpected error occured in poller instantiation")
+
+	poller.run()
+
+	go func() {
+		for {
+			if _, err := poller.Next(); err != nil {
+				return
+			}
+		}
+	}()
+
+	time.Sleep(time.Second)
+	poller.Close()
+
+	assert.True(atomic.LoadInt32(&polls) >= 4, "the periodic polls shouldn't be delayed by the events")
+}
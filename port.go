@@ -0,0 +1,303 @@
+package resolver
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/eddyzags/resolver/marathon"
+)
+
+const (
+	// portLabel is the label of a port mapping, a port definition or a
+	// pod endpoint exposing a service (format: RESOLVER_NAME={NAME})
+	portLabel = "RESOLVER_NAME"
+
+	// networkLabel is the label of an application or a pod overriding the
+	// addressing of its instances (format: RESOLVER_NETWORK=host|container)
+	networkLabel = "RESOLVER_NETWORK"
+
+	labelPrefix = "RESOLVER_"
+	labelSuffix = "_NAME"
+)
+
+// Addressing of the service instances
+const (
+	// networkHost addresses an instance with
+	// its agent host and its host port.
+	networkHost = "host"
+	// networkContainer addresses an instance with its
+	// own ip (ip-per-task) and its container port.
+	networkContainer = "container"
+)
+
+// servicePort is the port of an application exposing a service
+type servicePort struct {
+	// network is the addressing of the tasks
+	network string
+	// index is the index of the port in the task
+	// ports, -1 if the port has no host port.
+	index int64
+	// containerPort is the port in the
+	// container, 0 with host networking.
+	containerPort int
+	weights       *weights
+}
+
+// namedPort is a port of an application or an endpoint of a pod
+type namedPort struct {
+	name          string
+	labels        *map[string]string
+	containerPort int
+	// hostPort is false when the port is reachable
+	// with container networking only.
+	hostPort bool
+}
+
+// appPorts returns the ports of an application in the order of the task
+// ports: the port mappings with bridge and container networking, the
+// port definitions with host networking
+func appPorts(app *marathon.Application) []namedPort {
+	var ports []namedPort
+
+	switch mode := app.NetworkMode(); mode {
+	case marathon.NetworkBridge, marathon.NetworkContainer:
+		for _, m := range app.PortMappings() {
+			ports = append(ports, namedPort{
+				name:          m.Name,
+				labels:        m.Labels,
+				containerPort: m.ContainerPort,
+				// Every port mapping has a host
+				// port with bridge networking.
+				hostPort: mode == marathon.NetworkBridge || m.HostPort != nil,
+			})
+		}
+	default:
+		if app.PortDefinitions != nil {
+			for _, d := range *app.PortDefinitions {
+				ports = append(ports, namedPort{
+					name:     d.Name,
+					labels:   d.Labels,
+					hostPort: true,
+				})
+			}
+		}
+	}
+
+	return ports
+}
+
+// appPort returns the port exposing a service in the application tasks
+// or nil if the application doesn't expose it. The tasks are addressed
+// with their own ip and the container port with container networking,
+// with their host and the host port otherwise.
+func appPort(app *marathon.Application, name string) (*servicePort, error) {
+	ports := appPorts(app)
+
+	pos, err := findPort(ports, app.Labels, name)
+	if err != nil {
+		return nil, err
+	}
+
+	if pos < 0 {
+		return nil, nil
+	}
+
+	network, err := addressing(app.Labels, app.NetworkMode() == marathon.NetworkContainer)
+	if err != nil {
+		return nil, err
+	}
+
+	port := &servicePort{
+		network: network,
+		index:   -1,
+	}
+
+	// Without ports definition in marathon,
+	// the index refers to the task ports.
+	if len(ports) == 0 {
+		if network == networkContainer {
+			return nil, fmt.Errorf("no port mapping in %s for container networking", app.ID)
+		}
+
+		if port.weights, err = portWeights(app.Labels, pos, nil); err != nil {
+			return nil, err
+		}
+
+		port.index = pos
+		return port, nil
+	}
+
+	if port.weights, err = portWeights(app.Labels, pos, &ports[pos]); err != nil {
+		return nil, err
+	}
+
+	port.containerPort = ports[pos].containerPort
+
+	if ports[pos].hostPort {
+		port.index = 0
+		for _, p := range ports[:pos] {
+			if p.hostPort {
+				port.index++
+			}
+		}
+	}
+
+	switch {
+	case network == networkHost && port.index < 0:
+		return nil, fmt.Errorf("port %d of %s has no host port", pos, app.ID)
+	case network == networkContainer && port.containerPort == 0:
+		return nil, fmt.Errorf("port %d of %s has no container port", pos, app.ID)
+	}
+
+	return port, nil
+}
+
+// addressing returns the addressing of the instances set by the network
+// label or, by default, matching the network mode
+func addressing(labels *map[string]string, container bool) (string, error) {
+	if labels != nil {
+		if v, ok := (*labels)[networkLabel]; ok {
+			switch v {
+			case networkHost, networkContainer:
+				return v, nil
+			}
+
+			return "", fmt.Errorf("invalid %s label %q", networkLabel, v)
+		}
+	}
+
+	if container {
+		return networkContainer, nil
+	}
+
+	return networkHost, nil
+}
+
+// podPort returns the pod endpoint exposing a service or nil if the pod
+// doesn't expose it. The endpoints are indexed in the containers order.
+// The instances are addressed with their own ip and the container port
+// when the pod joins a container network, with their agent host and the
+// allocated host port otherwise.
+func podPort(pod *marathon.Pod, name string) (*podEndpoint, error) {
+	var ports []namedPort
+	var endpoints []*podEndpoint
+
+	for _, container := range pod.Containers {
+		for _, endpoint := range container.Endpoints {
+			ports = append(ports, namedPort{
+				name:     endpoint.Name,
+				labels:   endpoint.Labels,
+				hostPort: true,
+			})
+			endpoints = append(endpoints, &podEndpoint{
+				container: container.Name,
+				endpoint:  endpoint,
+			})
+		}
+	}
+
+	pos, err := findPort(ports, pod.Labels, name)
+	if err != nil {
+		return nil, err
+	}
+
+	if pos < 0 {
+		return nil, nil
+	}
+
+	if pos >= int64(len(endpoints)) {
+		return nil, errors.New("pod endpoint index out of range")
+	}
+
+	container := false
+	for _, network := range pod.Networks {
+		container = container || network.Mode == marathon.NetworkContainer
+	}
+
+	endpoint := endpoints[pos]
+
+	if endpoint.network, err = addressing(pod.Labels, container); err != nil {
+		return nil, err
+	}
+
+	if endpoint.weights, err = portWeights(pod.Labels, pos, &ports[pos]); err != nil {
+		return nil, err
+	}
+
+	return endpoint, nil
+}
+
+// findPort returns the position of the port exposing a service or -1 if
+// there is none. The port is designated by its own label or by a label of
+// the application referencing it by index or by name (format:
+// RESOLVER_{INDEX|PORTNAME}_NAME={NAME}).
+func findPort(ports []namedPort, labels *map[string]string, name string) (int64, error) {
+	for i, port := range ports {
+		if port.labels != nil && (*port.labels)[portLabel] == name {
+			return int64(i), nil
+		}
+	}
+
+	if labels == nil {
+		return -1, nil
+	}
+
+	// The labels are walked in order, several
+	// labels referencing the name must agree.
+	keys := make([]string, 0, len(*labels))
+	for k := range *labels {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	pos := int64(-1)
+	for _, k := range keys {
+		if (*labels)[k] != name || k == portLabel {
+			continue
+		}
+
+		if len(k) <= len(labelPrefix)+len(labelSuffix) ||
+			!strings.HasPrefix(k, labelPrefix) || !strings.HasSuffix(k, labelSuffix) {
+			continue
+		}
+
+		ref := k[len(labelPrefix) : len(k)-len(labelSuffix)]
+
+		index, err := strconv.ParseInt(ref, 10, 64)
+		if err == nil {
+			if index < 0 || (len(ports) > 0 && index >= int64(len(ports))) {
+				return -1, fmt.Errorf("port index %d out of range", index)
+			}
+
+			if pos >= 0 && pos != index {
+				return -1, fmt.Errorf("port labels of %s conflict", name)
+			}
+
+			pos = index
+			continue
+		}
+
+		found := int64(-1)
+		for i, port := range ports {
+			if port.name != "" && port.name == ref {
+				found = int64(i)
+				break
+			}
+		}
+
+		if found < 0 {
+			return -1, fmt.Errorf("port %s not found", ref)
+		}
+
+		if pos >= 0 && pos != found {
+			return -1, fmt.Errorf("port labels of %s conflict", name)
+		}
+
+		pos = found
+	}
+
+	return pos, nil
+}
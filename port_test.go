@@ -0,0 +1,234 @@
+package resolver
+
+import (
+	"testing"
+
+	"github.com/eddyzags/resolver/marathon"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func intPtr(i int) *int {
+	return &i
+}
+
+func TestAppPortIndexWithoutError(t *testing.T) {
+	assert := assert.New(t)
+
+	val := "service-test"
+
+	tests := []struct {
+		name string
+		app  *marathon.Application
+		port servicePort
+	}{
+		{
+			name: "index label without ports",
+			app: &marathon.Application{
+				Labels: &map[string]string{"RESOLVER_2_NAME": val},
+			},
+			port: servicePort{network: networkHost, index: 2},
+		},
+		{
+			name: "index and named port labels referencing the same port",
+			app: &marathon.Application{
+				Labels: &map[string]string{"RESOLVER_1_NAME": val, "RESOLVER_grpc_NAME": val},
+				PortDefinitions: &[]marathon.PortDefinition{
+					{Name: "http"},
+					{Name: "grpc"},
+				},
+			},
+			port: servicePort{network: networkHost, index: 1},
+		},
+		{
+			name: "named port label with host networking",
+			app: &marathon.Application{
+				Labels: &map[string]string{"RESOLVER_grpc_NAME": val},
+				PortDefinitions: &[]marathon.PortDefinition{
+					{Name: "http"},
+					{Name: "grpc"},
+				},
+			},
+			port: servicePort{network: networkHost, index: 1},
+		},
+		{
+			name: "port definition label",
+			app: &marathon.Application{
+				PortDefinitions: &[]marathon.PortDefinition{
+					{Name: "http"},
+					{Name: "grpc", Labels: &map[string]string{"RESOLVER_NAME": val}},
+				},
+			},
+			port: servicePort{network: networkHost, index: 1},
+		},
+		{
+			name: "port mapping label with legacy bridge networking",
+			app: &marathon.Application{
+				Container: &marathon.Container{
+					Docker: &marathon.Docker{
+						Network: "BRIDGE",
+						PortMappings: &[]marathon.PortMapping{
+							{Name: "http", ContainerPort: 80},
+							{Name: "admin", ContainerPort: 81},
+							{Name: "grpc", ContainerPort: 4242, Labels: &map[string]string{"RESOLVER_NAME": val}},
+						},
+					},
+				},
+			},
+			port: servicePort{network: networkHost, index: 2, containerPort: 4242},
+		},
+		{
+			name: "named port label with container networking",
+			app: &marathon.Application{
+				Labels:   &map[string]string{"RESOLVER_grpc_NAME": val},
+				Networks: []marathon.Network{{Mode: marathon.NetworkContainer, Name: "dcos"}},
+				Container: &marathon.Container{
+					PortMappings: &[]marathon.PortMapping{
+						{Name: "http", ContainerPort: 80},
+						{Name: "admin", ContainerPort: 81, HostPort: intPtr(0)},
+						{Name: "grpc", ContainerPort: 4242, HostPort: intPtr(0)},
+					},
+				},
+			},
+			port: servicePort{network: networkContainer, index: 1, containerPort: 4242},
+		},
+		{
+			name: "container port with legacy user networking",
+			app: &marathon.Application{
+				Container: &marathon.Container{
+					Docker: &marathon.Docker{
+						Network: "USER",
+						PortMappings: &[]marathon.PortMapping{
+							{Name: "grpc", ContainerPort: 4242, Labels: &map[string]string{"RESOLVER_NAME": val}},
+						},
+					},
+				},
+			},
+			port: servicePort{network: networkContainer, index: -1, containerPort: 4242},
+		},
+		{
+			name: "host port with network label override",
+			app: &marathon.Application{
+				Labels:   &map[string]string{"RESOLVER_grpc_NAME": val, "RESOLVER_NETWORK": "host"},
+				Networks: []marathon.Network{{Mode: marathon.NetworkContainer}},
+				Container: &marathon.Container{
+					PortMappings: &[]marathon.PortMapping{
+						{Name: "grpc", ContainerPort: 4242, HostPort: intPtr(31000)},
+					},
+				},
+			},
+			port: servicePort{network: networkHost, index: 0, containerPort: 4242},
+		},
+	}
+
+	for _, test := range tests {
+		port, err := appPort(test.app, val)
+		assert.NoError(err, "an unexpected error occured in port lookup: "+test.name)
+
+		assert.Equal(test.port.network, port.network, "the networks should be equals: "+test.name)
+		assert.Equal(test.port.index, port.index, "the port indexes should be equals: "+test.name)
+		assert.Equal(test.port.containerPort, port.containerPort, "the container ports should be equals: "+test.name)
+	}
+}
+
+func TestAppPortIndexWithError(t *testing.T) {
+	assert := assert.New(t)
+
+	val := "service-test"
+
+	tests := []struct {
+		name string
+		app  *marathon.Application
+	}{
+		{
+			name: "unknown port name",
+			app: &marathon.Application{
+				Labels:          &map[string]string{"RESOLVER_grpc_NAME": val},
+				PortDefinitions: &[]marathon.PortDefinition{{Name: "http"}},
+			},
+		},
+		{
+			name: "index out of range",
+			app: &marathon.Application{
+				Labels:          &map[string]string{"RESOLVER_1_NAME": val},
+				PortDefinitions: &[]marathon.PortDefinition{{Name: "http"}},
+			},
+		},
+		{
+			name: "port without host port",
+			app: &marathon.Application{
+				Labels:   &map[string]string{"RESOLVER_grpc_NAME": val, "RESOLVER_NETWORK": "host"},
+				Networks: []marathon.Network{{Mode: marathon.NetworkContainer}},
+				Container: &marathon.Container{
+					PortMappings: &[]marathon.PortMapping{{Name: "grpc", ContainerPort: 4242}},
+				},
+			},
+		},
+		{
+			name: "invalid network label",
+			app: &marathon.Application{
+				Labels: &map[string]string{"RESOLVER_0_NAME": val, "RESOLVER_NETWORK": "overlay"},
+			},
+		},
+		{
+			name: "conflicting port labels",
+			app: &marathon.Application{
+				Labels: &map[string]string{"RESOLVER_0_NAME": val, "RESOLVER_grpc_NAME": val},
+				PortDefinitions: &[]marathon.PortDefinition{
+					{Name: "http"},
+					{Name: "grpc"},
+				},
+			},
+		},
+		{
+			name: "container networking without port mapping",
+			app: &marathon.Application{
+				Labels:   &map[string]string{"RESOLVER_0_NAME": val},
+				Networks: []marathon.Network{{Mode: marathon.NetworkContainer}},
+			},
+		},
+	}
+
+	for _, test := range tests {
+		_, err := appPort(test.app, val)
+		assert.Error(err, "an error was expected in port lookup: "+test.name)
+	}
+}
+
+func TestAppPortWithoutLabel(t *testing.T) {
+	assert := assert.New(t)
+
+	app := &marathon.Application{
+		Labels: &map[string]string{"RESOLVER_0_NAME": "other"},
+	}
+
+	port, err := appPort(app, "service-test")
+	assert.NoError(err, "an unexpected error occured in port lookup")
+
+	assert.Nil(port, "the port should be nil")
+}
+
+func TestPodPortWithoutError(t *testing.T) {
+	assert := assert.New(t)
+
+	val := "service-test"
+
+	pod := &marathon.Pod{
+		Labels: &map[string]string{"RESOLVER_grpc_NAME": val},
+		Containers: []*marathon.PodContainer{
+			{Name: "proxy", Endpoints: []*marathon.PodEndpoint{{Name: "http"}}},
+			{Name: "server", Endpoints: []*marathon.PodEndpoint{{Name: "grpc"}}},
+		},
+	}
+
+	endpoint, err := podPort(pod, val)
+	assert.NoError(err, "an unexpected error occured in pod port lookup")
+
+	assert.Equal("server", endpoint.container, "the container names should be equals")
+	assert.Equal("grpc", endpoint.endpoint.Name, "the endpoint names should be equals")
+
+	endpoint, err = podPort(pod, "other")
+	assert.NoError(err, "an unexpected error occured in pod port lookup")
+
+	assert.Nil(endpoint, "the endpoint should be nil")
+}
@@ -5,47 +5,162 @@ import (
 	"time"
 
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/connectivity"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/status"
 )
 
+// healthCheckInterval is the interval between two health Check calls
+// and between two attempts to open a health Watch stream
+const healthCheckInterval = 5 * time.Second
+
+// GRPCProber probes the backends on their grpc connection state
+type GRPCProber struct {
+	// Timeout is the time allowed to the probe calls (default: 5s)
+	Timeout time.Duration
+	// DialOptions are the options of the probe connections
+	// (default: grpc.WithInsecure)
+	DialOptions []grpc.DialOption
+}
+
+// Probe implements Prober
+func (g *GRPCProber) Probe(ctx context.Context, name, addr string) (<-chan bool, error) {
+	probe, err := newProbe(addr, orDefault(g.Timeout, defaultProbeTimeout), g.DialOptions...)
+	if err != nil {
+		return nil, err
+	}
+
+	return probe.run(ctx), nil
+}
+
+// GRPCHealthProber probes the backends using the grpc health checking
+// protocol. A backend is ready when its service is SERVING. Servers which
+// don't implement the health service are probed on their connection
+// state.
+type GRPCHealthProber struct {
+	// Services associates a resolved name to the health service name
+	// to check. Names missing from the map check the overall server
+	// health.
+	Services map[string]string
+	// Timeout is the time allowed to the probe calls (default: 5s)
+	Timeout time.Duration
+	// DialOptions are the options of the probe connections
+	// (default: grpc.WithInsecure)
+	DialOptions []grpc.DialOption
+}
+
+// Probe implements Prober
+func (g *GRPCHealthProber) Probe(ctx context.Context, name, addr string) (<-chan bool, error) {
+	probe, err := newHealthProbe(addr, g.Services[name], orDefault(g.Timeout, defaultProbeTimeout), g.DialOptions...)
+	if err != nil {
+		return nil, err
+	}
+
+	return probe.run(ctx), nil
+}
+
+// Probe monitors a grpc server through a dedicated client connection
 type Probe struct {
-	addr   string
-	conn   *grpc.ClientConn
-	ctx    context.Context
-	cancel context.CancelFunc
+	addr    string
+	conn    *grpc.ClientConn
+	ctx     context.Context
+	cancel  context.CancelFunc
+	timeout time.Duration
+	health  bool
+	service string
 }
 
-func newProbe(addr string, timeout time.Duration) (*Probe, error) {
+func newProbe(addr string, timeout time.Duration, opts ...grpc.DialOption) (*Probe, error) {
 	ctx, cancel := context.WithCancel(context.Background())
 
-	conn, err := grpc.DialContext(ctx, addr, grpc.WithInsecure())
+	if len(opts) == 0 {
+		opts = []grpc.DialOption{grpc.WithInsecure()}
+	}
+
+	conn, err := grpc.DialContext(ctx, addr, opts...)
 	if err != nil {
+		cancel()
 		return nil, err
 	}
 
 	return &Probe{
-		addr:   addr,
-		conn:   conn,
-		ctx:    ctx,
-		cancel: cancel,
+		addr:    addr,
+		conn:    conn,
+		ctx:     ctx,
+		cancel:  cancel,
+		timeout: timeout,
 	}, nil
 }
 
+// newHealthProbe instantiates a probe using the grpc health checking
+// protocol for the given service. The probe falls back on the connection
+// state if the server doesn't implement the health service.
+func newHealthProbe(addr, service string, timeout time.Duration, opts ...grpc.DialOption) (*Probe, error) {
+	probe, err := newProbe(addr, timeout, opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	probe.health = true
+	probe.service = service
+
+	return probe, nil
+}
+
 func (p *Probe) exec() chan connectivity.State {
 	out := make(chan connectivity.State)
 
 	go func() {
 		defer close(out)
-		for {
-			current := p.conn.GetState()
 
-			out <- current
+		if p.health && p.watchHealth(out) {
+			return
+		}
 
-			ok := p.conn.WaitForStateChange(p.ctx, current)
-			if !ok {
-				if p.ctx.Err() == context.DeadlineExceeded {
-					out <- connectivity.TransientFailure
+		p.watchConnectivity(out)
+	}()
+
+	return out
+}
+
+// run converts the probe states to readiness reports until the context
+// is done. The probe is closed once the monitoring stops.
+func (p *Probe) run(ctx context.Context) <-chan bool {
+	out := make(chan bool)
+	states := p.exec()
+
+	go func() {
+		defer close(out)
+		defer p.close()
+
+		for {
+			var state connectivity.State
+			var ok bool
+
+			select {
+			case <-ctx.Done():
+				return
+			case state, ok = <-states:
+				if !ok {
+					return
 				}
+			}
+
+			var ready bool
+
+			switch state {
+			case connectivity.Ready:
+				ready = true
+			case connectivity.TransientFailure, connectivity.Shutdown:
+				ready = false
+			default:
+				continue
+			}
+
+			select {
+			case out <- ready:
+			case <-ctx.Done():
 				return
 			}
 		}
@@ -54,6 +169,139 @@ func (p *Probe) exec() chan connectivity.State {
 	return out
 }
 
+// watchConnectivity forwards the connection states until the probe is
+// closed. A failure is reported when the connection can't be established
+// within the probe timeout.
+func (p *Probe) watchConnectivity(out chan connectivity.State) {
+	for {
+		current := p.conn.GetState()
+
+		if !p.send(out, current) {
+			return
+		}
+
+		ctx, cancel := p.ctx, context.CancelFunc(func() {})
+		if current == connectivity.Idle || current == connectivity.Connecting {
+			ctx, cancel = context.WithTimeout(p.ctx, p.timeout)
+		}
+
+		ok := p.conn.WaitForStateChange(ctx, current)
+		cancel()
+
+		if !ok {
+			if p.ctx.Err() != nil {
+				return
+			}
+
+			// The connection couldn't be established in time
+			if !p.send(out, connectivity.TransientFailure) {
+				return
+			}
+		}
+	}
+}
+
+// watchHealth forwards the service serving status as connection states
+// until the probe is closed. The Watch rpc is preferred over the Check
+// one. It returns false if the server doesn't implement the health
+// service.
+func (p *Probe) watchHealth(out chan connectivity.State) bool {
+	client := healthpb.NewHealthClient(p.conn)
+	watch := true
+
+	for {
+		var err error
+		if watch {
+			err = p.watch(client, out)
+		} else {
+			err = p.check(client, out)
+		}
+
+		if p.ctx.Err() != nil {
+			return true
+		}
+
+		if status.Code(err) == codes.Unimplemented {
+			if !watch {
+				return false
+			}
+
+			watch = false
+			continue
+		}
+
+		if !p.send(out, connectivity.TransientFailure) {
+			return true
+		}
+
+		select {
+		case <-p.ctx.Done():
+			return true
+		case <-time.After(healthCheckInterval):
+		}
+	}
+}
+
+// watch streams the serving status until the stream fails
+func (p *Probe) watch(client healthpb.HealthClient, out chan connectivity.State) error {
+	stream, err := client.Watch(p.ctx, &healthpb.HealthCheckRequest{Service: p.service})
+	if err != nil {
+		return err
+	}
+
+	for {
+		resp, err := stream.Recv()
+		if err != nil {
+			return err
+		}
+
+		if !p.send(out, servingState(resp.GetStatus())) {
+			return p.ctx.Err()
+		}
+	}
+}
+
+// check polls the serving status until a call fails
+func (p *Probe) check(client healthpb.HealthClient, out chan connectivity.State) error {
+	for {
+		ctx, cancel := context.WithTimeout(p.ctx, p.timeout)
+		resp, err := client.Check(ctx, &healthpb.HealthCheckRequest{Service: p.service})
+		cancel()
+		if err != nil {
+			return err
+		}
+
+		if !p.send(out, servingState(resp.GetStatus())) {
+			return p.ctx.Err()
+		}
+
+		select {
+		case <-p.ctx.Done():
+			return p.ctx.Err()
+		case <-time.After(healthCheckInterval):
+		}
+	}
+}
+
+// send forwards a state unless the probe is closed
+func (p *Probe) send(out chan connectivity.State, state connectivity.State) bool {
+	select {
+	case out <- state:
+		return true
+	case <-p.ctx.Done():
+		return false
+	}
+}
+
+// servingState converts a serving status to a connection state
+func servingState(s healthpb.HealthCheckResponse_ServingStatus) connectivity.State {
+	if s == healthpb.HealthCheckResponse_SERVING {
+		return connectivity.Ready
+	}
+
+	return connectivity.TransientFailure
+}
+
 func (p *Probe) close() {
 	p.cancel()
 	if p.conn != nil {
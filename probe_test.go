@@ -10,6 +10,8 @@ import (
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/connectivity"
 	pb "google.golang.org/grpc/examples/helloworld/helloworld"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
 )
 
 type server struct{}
@@ -34,6 +36,24 @@ func newGRPCServer() (*grpc.Server, string, error) {
 	return s, lis.Addr().String(), nil
 }
 
+func newGRPCHealthServer() (*grpc.Server, *health.Server, string, error) {
+	lis, err := net.Listen("tcp", "localhost:")
+	if err != nil {
+		return nil, nil, "", err
+	}
+
+	s := grpc.NewServer()
+	h := health.NewServer()
+	pb.RegisterGreeterServer(s, &server{})
+	healthpb.RegisterHealthServer(s, h)
+
+	go func() {
+		_ = s.Serve(lis)
+	}()
+
+	return s, h, lis.Addr().String(), nil
+}
+
 func TestProbeExecWithoutError(t *testing.T) {
 	assert := assert.New(t)
 
@@ -60,3 +80,74 @@ func TestProbeExecWithoutError(t *testing.T) {
 
 	probe.close()
 }
+
+func TestProbeExecHealthWithoutError(t *testing.T) {
+	assert := assert.New(t)
+
+	grpcServer, healthServer, addr, err := newGRPCHealthServer()
+	assert.NoError(err, "an unexpected error occured in grpc server instantiation")
+
+	defer grpcServer.Stop()
+
+	healthServer.SetServingStatus("helloworld", healthpb.HealthCheckResponse_SERVING)
+
+	probe, err := newHealthProbe(addr, "helloworld", time.Second*5)
+	assert.NoError(err, "an unexpected error occured in probe instantiation")
+
+	defer probe.close()
+
+	out := probe.exec()
+
+	res := <-out
+	assert.Equal(connectivity.Ready, res, "The state should be ready")
+
+	healthServer.SetServingStatus("helloworld", healthpb.HealthCheckResponse_NOT_SERVING)
+
+	res = <-out
+	assert.Equal(connectivity.TransientFailure, res, "The state should be failure")
+}
+
+func TestProbeExecHealthUnknownService(t *testing.T) {
+	assert := assert.New(t)
+
+	grpcServer, _, addr, err := newGRPCHealthServer()
+	assert.NoError(err, "an unexpected error occured in grpc server instantiation")
+
+	defer grpcServer.Stop()
+
+	probe, err := newHealthProbe(addr, "unknown", time.Second*5)
+	assert.NoError(err, "an unexpected error occured in probe instantiation")
+
+	defer probe.close()
+
+	out := probe.exec()
+
+	res := <-out
+	assert.Equal(connectivity.TransientFailure, res, "The state should be failure")
+}
+
+func TestProbeExecHealthFallbackOnConnectivity(t *testing.T) {
+	assert := assert.New(t)
+
+	grpcServer, addr, err := newGRPCServer()
+	assert.NoError(err, "an unexpected error occured in grpc server instantiation")
+
+	defer grpcServer.Stop()
+
+	probe, err := newHealthProbe(addr, "", time.Second*5)
+	assert.NoError(err, "an unexpected error occured in probe instantiation")
+
+	defer probe.close()
+
+	out := probe.exec()
+
+	// The server doesn't implement the health service,
+	// the probe falls back on the connection state.
+	for res := range out {
+		if res == connectivity.Ready {
+			return
+		}
+	}
+
+	t.Fatal("The connection state should be ready")
+}
@@ -0,0 +1,149 @@
+package resolver
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"time"
+)
+
+const (
+	// defaultProbeInterval is the interval between two probes of the
+	// periodic probers
+	defaultProbeInterval = 5 * time.Second
+
+	// defaultProbeTimeout is the time allowed to a single probe
+	defaultProbeTimeout = 5 * time.Second
+)
+
+// Prober monitors the readiness of the backends resolved by marathon
+type Prober interface {
+	// Probe monitors a backend address resolved for the given service
+	// name until the context is done. The readiness of the backend is
+	// reported on the returned channel, which is closed once the
+	// monitoring stops.
+	Probe(ctx context.Context, name, addr string) (<-chan bool, error)
+}
+
+// TCPProber probes the backends by opening a tcp connection
+type TCPProber struct {
+	// Interval is the interval between two connections (default: 5s)
+	Interval time.Duration
+	// Timeout is the connection timeout (default: 5s)
+	Timeout time.Duration
+}
+
+// Probe implements Prober
+func (p *TCPProber) Probe(ctx context.Context, name, addr string) (<-chan bool, error) {
+	timeout := orDefault(p.Timeout, defaultProbeTimeout)
+
+	dialer := &net.Dialer{Timeout: timeout}
+
+	return periodic(ctx, orDefault(p.Interval, defaultProbeInterval), func() bool {
+		conn, err := dialer.DialContext(ctx, "tcp", addr)
+		if err != nil {
+			return false
+		}
+
+		_ = conn.Close()
+
+		return true
+	}), nil
+}
+
+// HTTPProber probes the backends with a http GET request. A backend is
+// ready when it responds with a status code between 200 and 399.
+type HTTPProber struct {
+	// Path is the request path (default: /)
+	Path string
+	// Scheme is the request scheme (default: http)
+	Scheme string
+	// Interval is the interval between two requests (default: 5s)
+	Interval time.Duration
+	// Timeout is the request timeout (default: 5s)
+	Timeout time.Duration
+	// Client is the http client sending the requests (default: http.DefaultClient)
+	Client *http.Client
+}
+
+// Probe implements Prober
+func (p *HTTPProber) Probe(ctx context.Context, name, addr string) (<-chan bool, error) {
+	scheme := p.Scheme
+	if scheme == "" {
+		scheme = "http"
+	}
+
+	path := p.Path
+	if path == "" {
+		path = "/"
+	}
+
+	client := p.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+
+	url := scheme + "://" + addr + path
+	timeout := orDefault(p.Timeout, defaultProbeTimeout)
+
+	return periodic(ctx, orDefault(p.Interval, defaultProbeInterval), func() bool {
+		rctx, cancel := context.WithTimeout(ctx, timeout)
+		defer cancel()
+
+		req, err := http.NewRequest("GET", url, nil)
+		if err != nil {
+			return false
+		}
+
+		resp, err := client.Do(req.WithContext(rctx))
+		if err != nil {
+			return false
+		}
+		_ = resp.Body.Close()
+
+		return resp.StatusCode >= 200 && resp.StatusCode < 400
+	}), nil
+}
+
+// periodic executes a probe at each interval until the context is done
+// and reports the readiness changes
+func periodic(ctx context.Context, interval time.Duration, probe func() bool) <-chan bool {
+	out := make(chan bool)
+
+	go func() {
+		defer close(out)
+
+		first := true
+		last := false
+
+		for {
+			ready := probe()
+
+			if first || ready != last {
+				select {
+				case out <- ready:
+				case <-ctx.Done():
+					return
+				}
+			}
+
+			first, last = false, ready
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(interval):
+			}
+		}
+	}()
+
+	return out
+}
+
+func orDefault(d, def time.Duration) time.Duration {
+	if d <= 0 {
+		return def
+	}
+
+	return d
+}
@@ -0,0 +1,90 @@
+package resolver
+
+import (
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/net/context"
+)
+
+func TestTCPProberWithoutError(t *testing.T) {
+	assert := assert.New(t)
+
+	lis, err := net.Listen("tcp", "localhost:")
+	assert.NoError(err, "an unexpected error occured in listener instantiation")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	prober := &TCPProber{Interval: 50 * time.Millisecond}
+
+	out, err := prober.Probe(ctx, "service-test", lis.Addr().String())
+	assert.NoError(err, "an unexpected error occured in probe")
+
+	assert.True(<-out, "the backend should be ready")
+
+	_ = lis.Close()
+
+	assert.False(<-out, "the backend shouldn't be ready")
+
+	cancel()
+
+	_, ok := <-out
+	assert.False(ok, "the channel should be closed")
+}
+
+func TestHTTPProberWithoutError(t *testing.T) {
+	assert := assert.New(t)
+
+	var failing int32
+
+	handler := http.HandlerFunc(func(rw http.ResponseWriter, rq *http.Request) {
+		if rq.URL.Path != "/health" || atomic.LoadInt32(&failing) == 1 {
+			rw.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		rw.WriteHeader(http.StatusOK)
+	})
+
+	ts := httptest.NewServer(handler)
+	defer ts.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	prober := &HTTPProber{Path: "/health", Interval: 50 * time.Millisecond}
+
+	out, err := prober.Probe(ctx, "service-test", strings.TrimPrefix(ts.URL, "http://"))
+	assert.NoError(err, "an unexpected error occured in probe")
+
+	assert.True(<-out, "the backend should be ready")
+
+	atomic.StoreInt32(&failing, 1)
+
+	assert.False(<-out, "the backend shouldn't be ready")
+}
+
+func TestGRPCProberWithoutError(t *testing.T) {
+	assert := assert.New(t)
+
+	grpcServer, addr, err := newGRPCServer()
+	assert.NoError(err, "an unexpected error occured in grpc server instantiation")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	out, err := (&GRPCProber{}).Probe(ctx, "service-test", addr)
+	assert.NoError(err, "an unexpected error occured in probe")
+
+	assert.True(<-out, "the backend should be ready")
+
+	grpcServer.Stop()
+
+	assert.False(<-out, "the backend shouldn't be ready")
+}
@@ -1,34 +1,62 @@
 package resolver
 
 import (
+	"errors"
+	"sync"
+
 	"github.com/eddyzags/resolver/marathon"
 
 	"google.golang.org/grpc/naming"
 )
 
+// Resolver is a grpc name resolver backed by marathon. It implements
+// both naming.Resolver and resolver.Builder.
 type Resolver struct {
 	marathon *marathon.Client
-	poller   *poll
+	opts     []Option
+	// pollers are the pollers of the
+	// names resolved, until closed.
+	mu      sync.Mutex
+	pollers map[*poll]bool
 }
 
-// New instantiates a new resolver given a marathon uri.
-func New(addr string) (*Resolver, error) {
-	m := marathon.NewClient(&marathon.Config{
-		URI: addr,
-	})
+// New instantiates a new resolver given a marathon uri and options.
+func New(addr string, opts ...Option) (*Resolver, error) {
+	o := newOptions(opts...)
+
+	if o.zone != "" && o.mesosURI == "" {
+		return nil, errors.New("the locality requires the mesos master uri")
+	}
+
+	m := marathon.NewClient(o.marathonConfig(addr))
 
 	if err := m.Ping(); err != nil {
-		return nil, err
+		switch {
+		case o.snapshot != nil && o.snapshot.available():
+			o.logger.Printf("marathon unreachable: %v. Starting from the snapshot %s...", err, o.snapshot.path)
+		case o.static != nil:
+			o.logger.Printf("marathon unreachable: %v. Starting with the static addresses...", err)
+		default:
+			return nil, err
+		}
 	}
 
+	// The pollers share one event stream.
+	stream := newStream(m, o.logger)
+	opts = append(opts[:len(opts):len(opts)], func(o *options) {
+		o.stream = stream
+	})
+
 	return &Resolver{
 		marathon: m,
+		opts:     opts,
+		pollers:  make(map[*poll]bool),
 	}, nil
 }
 
 // Resolver creates a watcher given a service name
 func (r *Resolver) Resolve(name string) (naming.Watcher, error) {
-	poll, err := newPoll(name, r.marathon)
+	poll, err := newPoll(name, r.marathon, r.opts...)
 	if err != nil {
 		return nil, err
 	}
@@ -36,10 +64,36 @@ func (r *Resolver) Resolve(name string) (naming.Watcher, error) {
 	// Running marathon poll
 	poll.run()
 
+	r.track(poll)
+
 	return poll, nil
 }
 
 // Ready returns true if one or more probes are monitoring a grpc server
 func (r *Resolver) Ready() bool {
-	return len(r.poller.probes) > 0
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	ready := false
+	for p := range r.pollers {
+		if p.ctx.Err() != nil {
+			// The watcher was closed.
+			delete(r.pollers, p)
+			continue
+		}
+
+		if p.ready() {
+			ready = true
+		}
+	}
+
+	return ready
+}
+
+// track adds a poller to the pollers checked by Ready
+func (r *Resolver) track(p *poll) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.pollers[p] = true
 }
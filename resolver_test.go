@@ -1,9 +1,16 @@
 package resolver
 
 import (
+	"io/ioutil"
 	"net/http"
 	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync/atomic"
 	"testing"
+	"time"
 
 	"github.com/eddyzags/resolver/marathon"
 
@@ -36,6 +43,53 @@ func TestResolverInstantiationWithErrorOnMarathon(t *testing.T) {
 	assert.Nil(resolver, "resolver should be nil")
 }
 
+func TestResolverInstantiationWithErrorOnLocality(t *testing.T) {
+	assert := assert.New(t)
+
+	resolver, err := New("http://marathon", WithLocality("a", 0.5))
+	assert.Error(err, "an error was expected without mesos master")
+
+	assert.Nil(resolver, "resolver should be nil")
+}
+
+func TestResolverInstantiationFromSnapshot(t *testing.T) {
+	assert := assert.New(t)
+
+	dir, err := ioutil.TempDir("", "snapshot")
+	assert.NoError(err, "an unexpected error occured in temp dir creation")
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "snapshot.json")
+
+	_, err = New("test-123", WithSnapshot(path))
+	assert.Error(err, "an error was expected without snapshot")
+
+	err = newSnapshot(path).save("service-test", map[string]*Metadata{"10.0.0.1:8080": {AppID: "/test", Weight: 1}})
+	assert.NoError(err, "an unexpected error occured in snapshot save")
+
+	resolver, err := New("test-123", WithSnapshot(path), WithLogger(&logger{}))
+	assert.NoError(err, "an unexpected error occured in resolver instantiation")
+
+	assert.NotNil(resolver, "resolver shouldn't be nil")
+}
+
+func TestResolveWithStaticWithoutMarathon(t *testing.T) {
+	assert := assert.New(t)
+
+	resolver, err := New("test-123", WithLogger(&logger{}), WithStatic(map[string]StaticEntry{
+		"service-test": {Addresses: []string{"127.0.0.1:8080"}},
+	}))
+	assert.NoError(err, "an unexpected error occured in resolver instantiation")
+
+	watcher, err := resolver.Resolve("service-test")
+	assert.NoError(err, "an unexpected error occured in resolve")
+
+	watcher.Close()
+
+	_, err = resolver.Resolve("service-other")
+	assert.Error(err, "an error was expected in resolve")
+}
+
 func TestResolveWithoutError(t *testing.T) {
 	assert := assert.New(t)
 
@@ -67,6 +121,66 @@ func TestResolveWithoutError(t *testing.T) {
 	watcher.Close()
 }
 
+func TestResolverReady(t *testing.T) {
+	assert := assert.New(t)
+
+	grpcServer, addr, err := newGRPCServer()
+	assert.NoError(err, "an unexpected error occured in grpc server instantiation")
+
+	defer grpcServer.Stop()
+
+	val := "service-test"
+
+	port, err := strconv.ParseInt(strings.Split(addr, ":")[1], 10, 32)
+	assert.NoError(err, "an unexpected error occured in grpc server port parsing")
+
+	apps := []*marathon.Application{
+		{
+			ID:     "/test",
+			Labels: &map[string]string{"RESOLVER_0_NAME": val},
+		},
+	}
+
+	tasks := []*marathon.Task{
+		{
+			ID:    "test.1",
+			AppID: apps[0].ID,
+			Host:  "127.0.0.1",
+			Ports: []int{int(port)},
+		},
+	}
+
+	handler := http.HandlerFunc(func(rw http.ResponseWriter, rq *http.Request) {
+		switch rq.URL.Path {
+		case "/v2/apps":
+			render.New().JSON(rw, http.StatusOK, apps)
+		case "/v2/apps/test/tasks":
+			render.New().JSON(rw, http.StatusOK, map[string]interface{}{"tasks": tasks})
+		}
+	})
+
+	ts := httptest.NewServer(handler)
+	defer ts.Close()
+
+	resolver, err := New(ts.URL)
+	assert.NoError(err, "an unexpected error occured in resolver instantiation")
+
+	assert.False(resolver.Ready(), "the resolver shouldn't be ready without watcher")
+
+	watcher, err := resolver.Resolve(val)
+	assert.NoError(err, "an unexpected error occured in resolve")
+
+	ups, err := watcher.Next()
+	assert.NoError(err, "an unexpected error occured in watcher next")
+
+	assert.Equal(1, len(ups), "The number of updates should be 1")
+	assert.True(resolver.Ready(), "the resolver should be ready")
+
+	watcher.Close()
+
+	assert.False(resolver.Ready(), "the resolver shouldn't be ready once the watcher is closed")
+}
+
 func TestResolveWithErrorOnLabelNotFound(t *testing.T) {
 	assert := assert.New(t)
 
@@ -87,3 +201,47 @@ func TestResolveWithErrorOnLabelNotFound(t *testing.T) {
 
 	assert.Nil(watcher, "the watcher should be nil")
 }
+
+func TestResolveSharesEventStream(t *testing.T) {
+	assert := assert.New(t)
+
+	var conns int32
+
+	handler := http.HandlerFunc(func(rw http.ResponseWriter, rq *http.Request) {
+		switch rq.URL.Path {
+		case "/v2/apps":
+			render.New().JSON(rw, http.StatusOK, []*marathon.Application{
+				{ID: "/first", Labels: &map[string]string{"RESOLVER_0_NAME": "first"}},
+				{ID: "/second", Labels: &map[string]string{"RESOLVER_0_NAME": "second"}},
+			})
+		case "/v2/events":
+			atomic.AddInt32(&conns, 1)
+
+			rw.Header().Set("Content-Type", "text/event-stream")
+			rw.WriteHeader(http.StatusOK)
+			rw.(http.Flusher).Flush()
+
+			<-rq.Context().Done()
+		default:
+			render.New().JSON(rw, http.StatusOK, map[string]interface{}{"tasks": []interface{}{}})
+		}
+	})
+
+	ts := httptest.NewServer(handler)
+	defer ts.Close()
+
+	resolver, err := New(ts.URL, WithLogger(&logger{}))
+	assert.NoError(err, "an unexpected error occured in resolver instantiation")
+
+	first, err := resolver.Resolve("first")
+	assert.NoError(err, "an unexpected error occured in resolve")
+	defer first.Close()
+
+	second, err := resolver.Resolve("second")
+	assert.NoError(err, "an unexpected error occured in resolve")
+	defer second.Close()
+
+	time.Sleep(500 * time.Millisecond)
+
+	assert.Equal(int32(1), atomic.LoadInt32(&conns), "the event stream should be shared")
+}
@@ -0,0 +1,170 @@
+package resolver
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+// snapshot is the last known good address set of the resolved names,
+// persisted to a local file so that a client can start while marathon is
+// unreachable
+type snapshot struct {
+	mu   sync.Mutex
+	path string
+}
+
+// snapshotService is the address set of a name in the snapshot file
+type snapshotService struct {
+	Updated   time.Time          `json:"updated"`
+	Addresses []*snapshotAddress `json:"addresses"`
+}
+
+// snapshotAddress is an address and its metadata in the snapshot file
+type snapshotAddress struct {
+	Addr   string `json:"addr"`
+	AppID  string `json:"appId,omitempty"`
+	Weight uint32 `json:"weight"`
+	Zone   string `json:"zone,omitempty"`
+	Rack   string `json:"rack,omitempty"`
+}
+
+func newSnapshot(path string) *snapshot {
+	return &snapshot{path: path}
+}
+
+// services reads the snapshot file. A missing file is an empty snapshot.
+func (s *snapshot) services() (map[string]*snapshotService, error) {
+	services := make(map[string]*snapshotService)
+
+	b, err := ioutil.ReadFile(s.path)
+	if os.IsNotExist(err) {
+		return services, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if err := json.Unmarshal(b, &services); err != nil {
+		return nil, err
+	}
+
+	return services, nil
+}
+
+// available returns true if the snapshot holds addresses for at least
+// one name
+func (s *snapshot) available() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	services, err := s.services()
+	if err != nil {
+		return false
+	}
+
+	for _, service := range services {
+		if len(service.Addresses) > 0 {
+			return true
+		}
+	}
+
+	return false
+}
+
+// load returns the addresses of a name and their metadata marked as
+// stale
+func (s *snapshot) load(name string) (map[string]*Metadata, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	services, err := s.services()
+	if err != nil {
+		return nil, err
+	}
+
+	addrs := make(map[string]*Metadata)
+
+	if service, ok := services[name]; ok {
+		for _, a := range service.Addresses {
+			addrs[a.Addr] = &Metadata{
+				AppID:  a.AppID,
+				Weight: a.Weight,
+				Zone:   a.Zone,
+				Rack:   a.Rack,
+				Stale:  true,
+			}
+		}
+	}
+
+	return addrs, nil
+}
+
+// save replaces the addresses of a name. The file is written atomically:
+// a temporary file is written next to it and renamed.
+func (s *snapshot) save(name string, addrs map[string]*Metadata) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	services, err := s.services()
+	if err != nil {
+		// A corrupted snapshot is replaced.
+		services = make(map[string]*snapshotService)
+	}
+
+	service := &snapshotService{Updated: time.Now().UTC()}
+
+	for addr, md := range addrs {
+		service.Addresses = append(service.Addresses, &snapshotAddress{
+			Addr:   addr,
+			AppID:  md.AppID,
+			Weight: md.Weight,
+			Zone:   md.Zone,
+			Rack:   md.Rack,
+		})
+	}
+
+	sort.Slice(service.Addresses, func(i, j int) bool {
+		return service.Addresses[i].Addr < service.Addresses[j].Addr
+	})
+
+	services[name] = service
+
+	b, err := json.MarshalIndent(services, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	f, err := ioutil.TempFile(filepath.Dir(s.path), filepath.Base(s.path)+".tmp")
+	if err != nil {
+		return err
+	}
+
+	if _, err := f.Write(b); err != nil {
+		_ = f.Close()
+		_ = os.Remove(f.Name())
+		return err
+	}
+
+	if err := f.Sync(); err != nil {
+		_ = f.Close()
+		_ = os.Remove(f.Name())
+		return err
+	}
+
+	if err := f.Close(); err != nil {
+		_ = os.Remove(f.Name())
+		return err
+	}
+
+	if err := os.Rename(f.Name(), s.path); err != nil {
+		_ = os.Remove(f.Name())
+		return err
+	}
+
+	return nil
+}
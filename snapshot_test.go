@@ -0,0 +1,72 @@
+package resolver
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSnapshotSaveAndLoad(t *testing.T) {
+	assert := assert.New(t)
+
+	dir, err := ioutil.TempDir("", "snapshot")
+	assert.NoError(err, "an unexpected error occured in temp dir creation")
+	defer os.RemoveAll(dir)
+
+	s := newSnapshot(filepath.Join(dir, "snapshot.json"))
+
+	assert.False(s.available(), "the snapshot shouldn't be available")
+
+	err = s.save("service-test", map[string]*Metadata{
+		"10.0.0.1:8080": {AppID: "/test", Weight: 2, Zone: "a", Rack: "r1"},
+	})
+	assert.NoError(err, "an unexpected error occured in snapshot save")
+
+	err = s.save("service-other", map[string]*Metadata{
+		"10.0.0.2:8080": {AppID: "/other", Weight: 1},
+	})
+	assert.NoError(err, "an unexpected error occured in snapshot save")
+
+	assert.True(s.available(), "the snapshot should be available")
+
+	addrs, err := s.load("service-test")
+	assert.NoError(err, "an unexpected error occured in snapshot load")
+
+	assert.Equal(map[string]*Metadata{
+		"10.0.0.1:8080": {AppID: "/test", Weight: 2, Zone: "a", Rack: "r1", Stale: true},
+	}, addrs, "the addresses should be equals")
+
+	files, err := ioutil.ReadDir(dir)
+	assert.NoError(err, "an unexpected error occured in dir read")
+
+	assert.Equal(1, len(files), "the temporary files should be renamed")
+}
+
+func TestSnapshotSaveOnCorruptedFile(t *testing.T) {
+	assert := assert.New(t)
+
+	dir, err := ioutil.TempDir("", "snapshot")
+	assert.NoError(err, "an unexpected error occured in temp dir creation")
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "snapshot.json")
+
+	err = ioutil.WriteFile(path, []byte("{"), 0644)
+	assert.NoError(err, "an unexpected error occured in file write")
+
+	s := newSnapshot(path)
+
+	_, err = s.load("service-test")
+	assert.Error(err, "an error was expected in snapshot load")
+
+	err = s.save("service-test", map[string]*Metadata{"10.0.0.1:8080": {AppID: "/test", Weight: 1}})
+	assert.NoError(err, "an unexpected error occured in snapshot save")
+
+	addrs, err := s.load("service-test")
+	assert.NoError(err, "an unexpected error occured in snapshot load")
+
+	assert.Equal(1, len(addrs), "the number of addresses should be 1")
+}
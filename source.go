@@ -0,0 +1,242 @@
+package resolver
+
+import (
+	"context"
+	"net"
+	"strconv"
+	"strings"
+
+	"github.com/eddyzags/resolver/marathon"
+)
+
+// source is a marathon application or pod running the service
+type source struct {
+	id            string
+	portIndex     int64
+	network       string
+	containerPort int
+	healthChecks  int
+	weights       *weights
+	// instances is the number of instances
+	// the application is scaled to.
+	instances int
+	// pod is the endpoint exposing the
+	// service when the source is a pod.
+	pod *podEndpoint
+}
+
+// podEndpoint is the pod endpoint exposing the service when the service
+// is a marathon pod
+type podEndpoint struct {
+	container string
+	endpoint  *marathon.PodEndpoint
+	network   string
+	weights   *weights
+}
+
+// target is a service instance address discovered in marathon
+type target struct {
+	addr   string
+	appID  string
+	weight uint32
+	// agentID is the mesos agent running the
+	// instance, zone and rack its attributes.
+	agentID string
+	zone    string
+	rack    string
+	// alive is false when the instance isn't
+	// running or its health checks failed.
+	alive bool
+}
+
+// metadata returns the metadata of the target address
+func (t *target) metadata() *Metadata {
+	return &Metadata{
+		AppID:  t.appID,
+		Weight: t.weight,
+		Zone:   t.zone,
+		Rack:   t.rack,
+	}
+}
+
+// discover returns the applications labeled with the service name or,
+// if there is none, the pods labeled with it. Every application is
+// listed: the name is the value of an application or port label, which
+// the marathon label selectors can't match. A misconfigured application
+// or pod is skipped, an error being returned only if none is valid.
+func discover(ctx context.Context, m *marathon.Client, label string, logger Logger) (map[string]*source, error) {
+	apps, err := m.ApplicationsContext(ctx, "")
+	if err != nil {
+		return nil, err
+	}
+
+	sources := make(map[string]*source)
+
+	var invalid error
+
+	for _, app := range apps {
+		port, err := appPort(app, label)
+		if err != nil {
+			logger.Printf("application %s skipped for %s: %v", app.ID, label, err)
+			invalid = err
+			continue
+		}
+
+		if port == nil {
+			continue
+		}
+
+		sources[app.ID] = &source{
+			id:            app.ID,
+			portIndex:     port.index,
+			network:       port.network,
+			containerPort: port.containerPort,
+			healthChecks:  len(app.HealthChecks),
+			weights:       port.weights,
+			instances:     1,
+		}
+
+		if app.Instances != nil {
+			sources[app.ID].instances = *app.Instances
+		}
+	}
+
+	if len(sources) > 0 {
+		return sources, nil
+	}
+
+	pods, err := m.PodsContext(ctx)
+	if err != nil && !marathon.IsNotFound(err) {
+		return nil, err
+	}
+
+	for _, pod := range pods {
+		endpoint, err := podPort(pod, label)
+		if err != nil {
+			logger.Printf("pod %s skipped for %s: %v", pod.ID, label, err)
+			invalid = err
+			continue
+		}
+
+		if endpoint == nil {
+			continue
+		}
+
+		sources[pod.ID] = &source{
+			id:        pod.ID,
+			weights:   endpoint.weights,
+			instances: 1,
+			pod:       endpoint,
+		}
+
+		if pod.Scaling != nil {
+			sources[pod.ID].instances = pod.Scaling.Instances
+		}
+	}
+
+	if len(sources) == 0 && invalid != nil {
+		return nil, invalid
+	}
+
+	return sources, nil
+}
+
+// targets retrieves the addresses of the service instances in marathon
+func (s *source) targets(ctx context.Context, m *marathon.Client) ([]*target, error) {
+	if s.pod != nil {
+		return s.podTargets(ctx, m)
+	}
+
+	tasks, err := m.TasksContext(ctx, s.id)
+	if err != nil {
+		return nil, err
+	}
+
+	targets := make([]*target, 0, len(tasks))
+	for _, task := range tasks {
+		var addr string
+		switch {
+		case s.network == networkContainer:
+			addr = task.IPAddr(s.containerPort)
+		case int64(len(task.Ports)) > s.portIndex:
+			addr = task.Addr(s.portIndex)
+		}
+
+		// The ports and ip addresses are allocated
+		// once the task has been launched.
+		if addr == "" {
+			continue
+		}
+
+		targets = append(targets, &target{
+			addr:    addr,
+			appID:   s.id,
+			weight:  s.weights.of(task.Version),
+			agentID: task.SlaveID,
+			alive:   task.Alive(s.healthChecks),
+		})
+	}
+
+	return targets, nil
+}
+
+// podTargets retrieves the addresses of the service endpoint on the pod
+// instances
+func (s *source) podTargets(ctx context.Context, m *marathon.Client) ([]*target, error) {
+	status, err := m.PodStatusContext(ctx, s.id)
+	if err != nil {
+		return nil, err
+	}
+
+	var targets []*target
+
+	for _, instance := range status.Instances {
+		for _, container := range instance.Containers {
+			if container.Name != s.pod.container {
+				continue
+			}
+
+			for _, endpoint := range container.Endpoints {
+				if endpoint.Name != s.pod.endpoint.Name {
+					continue
+				}
+
+				var addr string
+				switch {
+				case s.pod.network == networkContainer:
+					if len(instance.Networks) == 0 || len(instance.Networks[0].Addresses) == 0 {
+						continue
+					}
+					addr = net.JoinHostPort(instance.Networks[0].Addresses[0], strconv.Itoa(s.pod.endpoint.ContainerPort))
+				case endpoint.AllocatedHostPort > 0:
+					addr = net.JoinHostPort(instance.AgentHostname, strconv.Itoa(endpoint.AllocatedHostPort))
+				default:
+					continue
+				}
+
+				running := container.Status == "" || container.Status == marathon.TaskRunning
+				healthy := endpoint.Healthy == nil || *endpoint.Healthy
+
+				targets = append(targets, &target{
+					addr:    addr,
+					appID:   s.id,
+					weight:  s.weights.of(podVersion(instance)),
+					agentID: instance.AgentID,
+					alive:   running && healthy,
+				})
+			}
+		}
+	}
+
+	return targets, nil
+}
+
+// podVersion returns the version of the pod run by an instance
+func podVersion(instance *marathon.PodInstance) string {
+	i := strings.LastIndex(instance.SpecReference, "versions/")
+	if i < 0 {
+		return ""
+	}
+
+	return instance.SpecReference[i+len("versions/"):]
+}
@@ -0,0 +1,181 @@
+package resolver
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sync"
+	"time"
+)
+
+// StaticMode defines how the static addresses of a name are combined
+// with the marathon ones
+type StaticMode string
+
+const (
+	// StaticReplace resolves the static addresses only, marathon being
+	// ignored (default)
+	StaticReplace StaticMode = "replace"
+	// StaticMerge resolves the static addresses along with the
+	// marathon ones
+	StaticMerge StaticMode = "merge"
+	// StaticFallback resolves the static addresses only when marathon
+	// returns no address
+	StaticFallback StaticMode = "fallback"
+)
+
+// defaultStaticInterval is the default interval between two checks of
+// the static addresses file
+const defaultStaticInterval = 5 * time.Second
+
+// StaticEntry is the static addresses of a name. The static addresses
+// are probed like the marathon ones.
+type StaticEntry struct {
+	Mode      StaticMode `json:"mode,omitempty"`
+	Addresses []string   `json:"addresses"`
+}
+
+// mode returns the mode of the entry, replace by default
+func (e *StaticEntry) mode() StaticMode {
+	if e.Mode == "" {
+		return StaticReplace
+	}
+
+	return e.Mode
+}
+
+// validate returns an error if the mode of the entry is unknown
+func (e *StaticEntry) validate() error {
+	switch e.mode() {
+	case StaticReplace, StaticMerge, StaticFallback:
+		return nil
+	}
+
+	return fmt.Errorf("unknown static mode %q", e.Mode)
+}
+
+// targets returns the static addresses as alive targets
+func (e *StaticEntry) targets() []*target {
+	targets := make([]*target, 0, len(e.Addresses))
+
+	for _, addr := range e.Addresses {
+		targets = append(targets, &target{
+			addr:   addr,
+			weight: defaultWeight,
+			alive:  true,
+		})
+	}
+
+	return targets
+}
+
+// combine returns the targets to resolve given the marathon targets
+func (e *StaticEntry) combine(targets []*target) []*target {
+	switch e.mode() {
+	case StaticReplace:
+		return e.targets()
+	case StaticFallback:
+		if len(targets) > 0 {
+			return targets
+		}
+		return e.targets()
+	}
+
+	// The marathon targets take precedence
+	// over the static ones.
+	present := make(map[string]bool, len(targets))
+	for _, t := range targets {
+		present[t.addr] = true
+	}
+
+	for _, t := range e.targets() {
+		if !present[t.addr] {
+			targets = append(targets, t)
+		}
+	}
+
+	return targets
+}
+
+// static is the static addresses of the resolved names, set in the
+// options or loaded from a json file reloaded on change
+type static struct {
+	mu       sync.Mutex
+	path     string
+	interval time.Duration
+	modTime  time.Time
+	version  int
+	entries  map[string]*StaticEntry
+}
+
+func newStatic(entries map[string]StaticEntry) *static {
+	s := &static{entries: make(map[string]*StaticEntry, len(entries))}
+
+	for name, entry := range entries {
+		entry := entry
+		s.entries[name] = &entry
+	}
+
+	return s
+}
+
+func newStaticFile(path string, interval time.Duration) *static {
+	return &static{
+		path:     path,
+		interval: orDefault(interval, defaultStaticInterval),
+		entries:  make(map[string]*StaticEntry),
+	}
+}
+
+// entry returns the static addresses of a name and the version of the
+// entries, or nil if there is none
+func (s *static) entry(name string) (*StaticEntry, int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.entries[name], s.version
+}
+
+// reload reads the file again if it was modified since the last load.
+// The entries are kept as is when the file is invalid.
+func (s *static) reload() error {
+	if s.path == "" {
+		return nil
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	info, err := os.Stat(s.path)
+	if err != nil {
+		return err
+	}
+
+	if info.ModTime().Equal(s.modTime) {
+		return nil
+	}
+
+	b, err := ioutil.ReadFile(s.path)
+	if err != nil {
+		return err
+	}
+
+	entries := make(map[string]*StaticEntry)
+
+	if err := json.Unmarshal(b, &entries); err != nil {
+		return err
+	}
+
+	for name, entry := range entries {
+		if err := entry.validate(); err != nil {
+			return fmt.Errorf("%s: %v", name, err)
+		}
+	}
+
+	s.entries = entries
+	s.modTime = info.ModTime()
+	s.version++
+
+	return nil
+}
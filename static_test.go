@@ -0,0 +1,96 @@
+package resolver
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func targetAddrs(targets []*target) []string {
+	result := make([]string, 0, len(targets))
+	for _, t := range targets {
+		result = append(result, t.addr)
+	}
+
+	return result
+}
+
+func TestStaticEntryCombine(t *testing.T) {
+	assert := assert.New(t)
+
+	tasks := []*target{{addr: "10.0.0.1:8080"}, {addr: "10.0.0.2:8080"}}
+
+	tests := []struct {
+		mode     StaticMode
+		targets  []*target
+		expected []string
+	}{
+		{"", tasks, []string{"10.0.0.2:8080", "127.0.0.1:8080"}},
+		{StaticReplace, tasks, []string{"10.0.0.2:8080", "127.0.0.1:8080"}},
+		{StaticMerge, tasks, []string{"10.0.0.1:8080", "10.0.0.2:8080", "127.0.0.1:8080"}},
+		{StaticFallback, tasks, []string{"10.0.0.1:8080", "10.0.0.2:8080"}},
+		{StaticFallback, []*target{}, []string{"10.0.0.2:8080", "127.0.0.1:8080"}},
+	}
+
+	for _, test := range tests {
+		entry := &StaticEntry{
+			Mode:      test.mode,
+			Addresses: []string{"10.0.0.2:8080", "127.0.0.1:8080"},
+		}
+
+		assert.Equal(test.expected, targetAddrs(entry.combine(test.targets)), "the addresses should be equals")
+	}
+}
+
+func TestStaticEntryValidate(t *testing.T) {
+	assert := assert.New(t)
+
+	assert.NoError((&StaticEntry{}).validate(), "an unexpected error occured in validation")
+	assert.NoError((&StaticEntry{Mode: StaticFallback}).validate(), "an unexpected error occured in validation")
+	assert.Error((&StaticEntry{Mode: "unknown"}).validate(), "an error was expected in validation")
+}
+
+func TestStaticReload(t *testing.T) {
+	assert := assert.New(t)
+
+	dir, err := ioutil.TempDir("", "static")
+	assert.NoError(err, "an unexpected error occured in temp dir creation")
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "static.json")
+
+	s := newStaticFile(path, 0)
+
+	assert.Error(s.reload(), "an error was expected without file")
+
+	err = ioutil.WriteFile(path, []byte(`{"service-test": {"mode": "merge", "addresses": ["127.0.0.1:8080"]}}`), 0644)
+	assert.NoError(err, "an unexpected error occured in file write")
+
+	assert.NoError(s.reload(), "an unexpected error occured in reload")
+
+	entry, version := s.entry("service-test")
+	assert.Equal(&StaticEntry{Mode: StaticMerge, Addresses: []string{"127.0.0.1:8080"}}, entry, "the entries should be equals")
+	assert.Equal(1, version, "the versions should be equals")
+
+	assert.NoError(s.reload(), "an unexpected error occured in reload")
+
+	_, version = s.entry("service-test")
+	assert.Equal(1, version, "the unmodified file shouldn't be loaded again")
+
+	// An invalid file keeps the entries.
+	err = ioutil.WriteFile(path, []byte(`{"service-test": {"mode": "unknown"}}`), 0644)
+	assert.NoError(err, "an unexpected error occured in file write")
+
+	modTime := time.Now().Add(time.Minute)
+	assert.NoError(os.Chtimes(path, modTime, modTime), "an unexpected error occured in file times change")
+
+	assert.Error(s.reload(), "an error was expected in reload")
+
+	entry, version = s.entry("service-test")
+	assert.Equal(StaticMerge, entry.Mode, "the modes should be equals")
+	assert.Equal(1, version, "the versions should be equals")
+}
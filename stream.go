@@ -0,0 +1,181 @@
+package resolver
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/eddyzags/resolver/marathon"
+)
+
+const (
+	// minSubscribeInterval and maxSubscribeInterval bound the backoff
+	// between two subscriptions to the marathon event stream
+	minSubscribeInterval = time.Second
+	maxSubscribeInterval = 30 * time.Second
+
+	// listenerBuffer is the number of events buffered for a poller
+	listenerBuffer = 256
+)
+
+// Events sent by the stream to the pollers along with the marathon ones
+const (
+	// eventStreamOpen is sent once the poller listens to the event
+	// stream, the events missed until then being unknown.
+	eventStreamOpen = "resolver_stream_open"
+	// eventsLost is sent once events were dropped, the poller being
+	// too slow to read them.
+	eventsLost = "resolver_events_lost"
+)
+
+// streamEvents are the marathon events the pollers react to
+var streamEvents = []string{
+	marathon.EventStreamAttached,
+	marathon.EventStatusUpdate,
+	marathon.EventHealthStatusChanged,
+	marathon.EventInstanceChanged,
+	marathon.EventInstanceHealthChanged,
+	marathon.EventAPIPost,
+	marathon.EventAppTerminated,
+	marathon.EventPodCreated,
+	marathon.EventPodUpdated,
+	marathon.EventPodDeleted,
+	marathon.EventDeploymentSuccess,
+}
+
+// stream shares the marathon event stream between the pollers of a
+// resolver. The stream is opened with the first listener and closed with
+// the last one. A failed subscription is retried with a backoff, the
+// pollers polling marathon meanwhile.
+type stream struct {
+	marathon  *marathon.Client
+	logger    Logger
+	mu        sync.Mutex
+	listeners map[*listener]bool
+	open      bool
+	cancel    context.CancelFunc
+}
+
+// listener is a poller listening to the event stream
+type listener struct {
+	events chan *marathon.Event
+	// lost is true when events were dropped
+	// and eventsLost wasn't sent yet.
+	lost bool
+}
+
+func newStream(m *marathon.Client, logger Logger) *stream {
+	return &stream{
+		marathon:  m,
+		logger:    logger,
+		listeners: make(map[*listener]bool),
+	}
+}
+
+// listen returns the events of the stream and the function to call to
+// stop listening
+func (s *stream) listen() (<-chan *marathon.Event, func()) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	l := &listener{events: make(chan *marathon.Event, listenerBuffer)}
+	s.listeners[l] = true
+
+	if s.open {
+		l.events <- &marathon.Event{Type: eventStreamOpen}
+	}
+
+	if s.cancel == nil {
+		ctx, cancel := context.WithCancel(context.Background())
+		s.cancel = cancel
+		go s.run(ctx)
+	}
+
+	return l.events, func() { s.unlisten(l) }
+}
+
+func (s *stream) unlisten(l *listener) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.listeners, l)
+
+	if len(s.listeners) == 0 && s.cancel != nil {
+		s.cancel()
+		s.cancel, s.open = nil, false
+	}
+}
+
+// run subscribes to the event stream until it succeeds and forwards the
+// events to the listeners until the context is done
+func (s *stream) run(ctx context.Context) {
+	var sub *marathon.Subscription
+
+	for backoff := minSubscribeInterval; ; {
+		var err error
+
+		sub, err = s.marathon.SubscribeContext(ctx, streamEvents...)
+		if err == nil {
+			break
+		}
+
+		if ctx.Err() != nil {
+			return
+		}
+
+		s.logger.Printf("couldn't subscribe to marathon events: %v. Polling, retrying in %v...", err, backoff)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(backoff):
+		}
+
+		if backoff *= 2; backoff > maxSubscribeInterval {
+			backoff = maxSubscribeInterval
+		}
+	}
+
+	defer sub.Close()
+
+	s.mu.Lock()
+	if ctx.Err() != nil {
+		// The listeners are gone.
+		s.mu.Unlock()
+		return
+	}
+	s.open = true
+	s.mu.Unlock()
+
+	s.dispatch(&marathon.Event{Type: eventStreamOpen})
+
+	for event := range sub.Events() {
+		if ctx.Err() == nil {
+			s.dispatch(event)
+		}
+	}
+}
+
+// dispatch forwards an event to the listeners. The event is dropped for
+// the listeners whose buffer is full, which are sent eventsLost later.
+func (s *stream) dispatch(event *marathon.Event) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for l := range s.listeners {
+		if l.lost {
+			select {
+			case l.events <- &marathon.Event{Type: eventsLost}:
+				l.lost = false
+			default:
+				continue
+			}
+		}
+
+		select {
+		case l.events <- event:
+		default:
+			l.lost = true
+		}
+	}
+}
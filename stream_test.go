@@ -0,0 +1,141 @@
+package resolver
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/eddyzags/resolver/marathon"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// newStreamServer returns a server streaming an event per connection, the
+// first failures connections being rejected. The closed channel receives
+// the streams closed by the clients.
+func newStreamServer(failures int32, conns *int32, closed chan struct{}) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, rq *http.Request) {
+		if rq.URL.Path != "/v2/events" {
+			rw.WriteHeader(http.StatusNotFound)
+			return
+		}
+
+		if atomic.AddInt32(conns, 1) <= failures {
+			rw.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+
+		rw.Header().Set("Content-Type", "text/event-stream")
+		rw.WriteHeader(http.StatusOK)
+
+		fmt.Fprintf(rw, "event: %s\ndata: {\"eventType\":\"%s\",\"appId\":\"/test\"}\n\n",
+			marathon.EventStatusUpdate, marathon.EventStatusUpdate)
+		rw.(http.Flusher).Flush()
+
+		<-rq.Context().Done()
+
+		select {
+		case closed <- struct{}{}:
+		default:
+		}
+	}))
+}
+
+func nextStreamEvent(t *testing.T, events <-chan *marathon.Event) *marathon.Event {
+	select {
+	case event := <-events:
+		return event
+	case <-time.After(5 * time.Second):
+		t.Fatal("no event received")
+	}
+
+	return nil
+}
+
+func TestStreamRetryOnSubscriptionFailure(t *testing.T) {
+	assert := assert.New(t)
+
+	var conns int32
+
+	ts := newStreamServer(1, &conns, make(chan struct{}, 1))
+	defer ts.Close()
+
+	l := &logger{}
+
+	s := newStream(marathon.NewClient(&marathon.Config{URI: ts.URL}), l)
+
+	events, stop := s.listen()
+	defer stop()
+
+	event := nextStreamEvent(t, events)
+	assert.Equal(eventStreamOpen, event.Type, "the event types should be equals")
+
+	event = nextStreamEvent(t, events)
+	assert.Equal(marathon.EventStatusUpdate, event.Type, "the event types should be equals")
+
+	assert.Equal(int32(2), atomic.LoadInt32(&conns), "the subscription should be retried once")
+	assert.True(l.contains("couldn't subscribe to marathon events"), "the failure should be logged")
+}
+
+func TestStreamSharedByListeners(t *testing.T) {
+	assert := assert.New(t)
+
+	var conns int32
+	closed := make(chan struct{}, 1)
+
+	ts := newStreamServer(0, &conns, closed)
+	defer ts.Close()
+
+	s := newStream(marathon.NewClient(&marathon.Config{URI: ts.URL}), &logger{})
+
+	first, stopFirst := s.listen()
+
+	assert.Equal(eventStreamOpen, nextStreamEvent(t, first).Type, "the event types should be equals")
+	assert.Equal(marathon.EventStatusUpdate, nextStreamEvent(t, first).Type, "the event types should be equals")
+
+	// The stream is already open.
+	second, stopSecond := s.listen()
+
+	assert.Equal(eventStreamOpen, nextStreamEvent(t, second).Type, "the event types should be equals")
+	assert.Equal(int32(1), atomic.LoadInt32(&conns), "the stream should be shared")
+
+	stopFirst()
+
+	select {
+	case <-closed:
+		t.Fatal("the stream shouldn't be closed while listened to")
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	stopSecond()
+
+	select {
+	case <-closed:
+	case <-time.After(5 * time.Second):
+		t.Fatal("the stream should be closed with the last listener")
+	}
+}
+
+func TestStreamEventsLost(t *testing.T) {
+	assert := assert.New(t)
+
+	s := newStream(marathon.NewClient(&marathon.Config{URI: "http://marathon"}), &logger{})
+
+	l := &listener{events: make(chan *marathon.Event, 1)}
+	s.listeners[l] = true
+
+	event := &marathon.Event{Type: marathon.EventStatusUpdate}
+
+	s.dispatch(event)
+	s.dispatch(event)
+
+	assert.True(l.lost, "the event should be dropped")
+
+	<-l.events
+	s.dispatch(event)
+
+	assert.Equal(eventsLost, (<-l.events).Type, "the event types should be equals")
+}
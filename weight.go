@@ -0,0 +1,102 @@
+package resolver
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+const (
+	// weightLabel is the label of a port mapping, a port definition or a
+	// pod endpoint setting the weight of the instances (format:
+	// RESOLVER_WEIGHT[_{VERSION}]={WEIGHT})
+	weightLabel = "RESOLVER_WEIGHT"
+
+	weightSuffix = "_WEIGHT"
+
+	// defaultWeight is the weight of the instances without weight label
+	defaultWeight = 1
+)
+
+// weights are the weights of the instances of an application. The
+// weight of a version overrides the weight of the application.
+type weights struct {
+	weight   uint32
+	versions map[string]uint32
+}
+
+func newWeights() *weights {
+	return &weights{
+		weight:   defaultWeight,
+		versions: make(map[string]uint32),
+	}
+}
+
+// of returns the weight of an instance given its version
+func (w *weights) of(version string) uint32 {
+	if weight, ok := w.versions[version]; ok {
+		return weight
+	}
+
+	return w.weight
+}
+
+// parse reads the weights set by the labels with the given key, suffixed
+// by a version for the version weights
+func (w *weights) parse(labels *map[string]string, key string) error {
+	if labels == nil {
+		return nil
+	}
+
+	for k, v := range *labels {
+		var version string
+		switch {
+		case k == key:
+		case strings.HasPrefix(k, key+"_"):
+			version = strings.TrimPrefix(k, key+"_")
+		default:
+			continue
+		}
+
+		weight, err := strconv.ParseUint(v, 10, 32)
+		if err != nil {
+			return fmt.Errorf("invalid weight %q in label %s", v, k)
+		}
+
+		if version == "" {
+			w.weight = uint32(weight)
+		} else {
+			w.versions[version] = uint32(weight)
+		}
+	}
+
+	return nil
+}
+
+// portWeights returns the weights of the instances exposing the port at
+// the given position. They are set by the labels of the application
+// referencing the port by index or by name (format:
+// RESOLVER_{INDEX|PORTNAME}_WEIGHT[_{VERSION}]={WEIGHT}) and overridden
+// by the labels of the port itself.
+func portWeights(labels *map[string]string, pos int64, port *namedPort) (*weights, error) {
+	w := newWeights()
+
+	refs := []string{strconv.FormatInt(pos, 10)}
+	if port != nil && port.name != "" {
+		refs = append(refs, port.name)
+	}
+
+	for _, ref := range refs {
+		if err := w.parse(labels, labelPrefix+ref+weightSuffix); err != nil {
+			return nil, err
+		}
+	}
+
+	if port != nil {
+		if err := w.parse(port.labels, weightLabel); err != nil {
+			return nil, err
+		}
+	}
+
+	return w, nil
+}
@@ -0,0 +1,62 @@
+package resolver
+
+import (
+	"testing"
+
+	"github.com/eddyzags/resolver/marathon"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAppPortWeightsWithoutError(t *testing.T) {
+	assert := assert.New(t)
+
+	val := "service-test"
+
+	app := &marathon.Application{
+		Labels: &map[string]string{
+			"RESOLVER_grpc_NAME":                            val,
+			"RESOLVER_1_WEIGHT":                             "2",
+			"RESOLVER_grpc_WEIGHT_2019-01-01T00:00:00.000Z": "5",
+			"RESOLVER_0_WEIGHT":                             "7",
+		},
+		PortDefinitions: &[]marathon.PortDefinition{
+			{Name: "http"},
+			{Name: "grpc", Labels: &map[string]string{"RESOLVER_WEIGHT_2019-02-01T00:00:00.000Z": "0"}},
+		},
+	}
+
+	port, err := appPort(app, val)
+	assert.NoError(err, "an unexpected error occured in port lookup")
+
+	assert.Equal(uint32(2), port.weights.of(""), "the weights should be equals")
+	assert.Equal(uint32(5), port.weights.of("2019-01-01T00:00:00.000Z"), "the weights should be equals")
+	assert.Equal(uint32(0), port.weights.of("2019-02-01T00:00:00.000Z"), "the weights should be equals")
+}
+
+func TestAppPortWeightsWithDefault(t *testing.T) {
+	assert := assert.New(t)
+
+	app := &marathon.Application{
+		Labels: &map[string]string{"RESOLVER_0_NAME": "service-test"},
+	}
+
+	port, err := appPort(app, "service-test")
+	assert.NoError(err, "an unexpected error occured in port lookup")
+
+	assert.Equal(uint32(defaultWeight), port.weights.of("2019-01-01T00:00:00.000Z"), "the weights should be equals")
+}
+
+func TestAppPortWeightsWithErrorOnSyntax(t *testing.T) {
+	assert := assert.New(t)
+
+	app := &marathon.Application{
+		Labels: &map[string]string{
+			"RESOLVER_0_NAME":   "service-test",
+			"RESOLVER_0_WEIGHT": "-1",
+		},
+	}
+
+	_, err := appPort(app, "service-test")
+	assert.Error(err, "an error was expected in port lookup")
+}
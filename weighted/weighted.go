@@ -0,0 +1,90 @@
+// Package weighted defines a weighted round-robin balancer using the
+// weights of the addresses resolved by the marathon resolver. The
+// balancer is registered on import:
+//
+//     import _ "github.com/eddyzags/resolver/weighted"
+//
+//     conn, err := grpc.Dial("marathon:///my-app-service", grpc.WithBalancerName(weighted.Name))
+package weighted
+
+import (
+	"sync"
+
+	"github.com/eddyzags/resolver"
+
+	"google.golang.org/grpc/balancer"
+	"google.golang.org/grpc/balancer/base"
+)
+
+// Name is the name of the weighted round-robin balancer
+const Name = "marathon_weighted_round_robin"
+
+func init() {
+	balancer.Register(base.NewBalancerBuilderV2(Name, &pickerBuilder{}, base.Config{HealthCheck: true}))
+}
+
+type pickerBuilder struct{}
+
+// Build returns a picker distributing the requests between the ready
+// connections according to the weights of their addresses. The addresses
+// without weight have a weight of 1.
+func (*pickerBuilder) Build(info base.PickerBuildInfo) balancer.V2Picker {
+	if len(info.ReadySCs) == 0 {
+		return base.NewErrPickerV2(balancer.ErrNoSubConnAvailable)
+	}
+
+	p := &picker{}
+
+	for sc, sci := range info.ReadySCs {
+		weight := int64(1)
+		if md := resolver.AddressMetadata(sci.Address); md != nil {
+			weight = int64(md.Weight)
+		}
+
+		p.subConns = append(p.subConns, &subConn{sc: sc, weight: weight})
+		p.total += weight
+	}
+
+	return p
+}
+
+// subConn is a ready connection and its smooth round-robin state
+type subConn struct {
+	sc      balancer.SubConn
+	weight  int64
+	current int64
+}
+
+// picker is a smooth weighted round-robin picker: the picks of a
+// connection are spread evenly rather than sent in bursts.
+type picker struct {
+	mu       sync.Mutex
+	subConns []*subConn
+	total    int64
+	next     int
+}
+
+func (p *picker) Pick(balancer.PickInfo) (balancer.PickResult, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	// With only zero weights, the
+	// connections are picked in turn.
+	if p.total == 0 {
+		sc := p.subConns[p.next]
+		p.next = (p.next + 1) % len(p.subConns)
+		return balancer.PickResult{SubConn: sc.sc}, nil
+	}
+
+	var best *subConn
+	for _, sc := range p.subConns {
+		sc.current += sc.weight
+		if best == nil || sc.current > best.current {
+			best = sc
+		}
+	}
+
+	best.current -= p.total
+
+	return balancer.PickResult{SubConn: best.sc}, nil
+}
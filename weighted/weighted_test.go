@@ -0,0 +1,87 @@
+package weighted
+
+import (
+	"testing"
+
+	"github.com/eddyzags/resolver"
+
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc/balancer"
+	"google.golang.org/grpc/balancer/base"
+	grpcresolver "google.golang.org/grpc/resolver"
+)
+
+type fakeSubConn struct {
+	balancer.SubConn
+	name string
+}
+
+func TestPickerWithWeights(t *testing.T) {
+	assert := assert.New(t)
+
+	heavy := &fakeSubConn{name: "heavy"}
+	light := &fakeSubConn{name: "light"}
+	unset := &fakeSubConn{name: "unset"}
+
+	info := base.PickerBuildInfo{
+		ReadySCs: map[balancer.SubConn]base.SubConnInfo{
+			heavy: {Address: address("10.0.0.1:8080", 3)},
+			light: {Address: address("10.0.0.2:8080", 0)},
+			unset: {Address: grpcresolver.Address{Addr: "10.0.0.3:8080"}},
+		},
+	}
+
+	p := (&pickerBuilder{}).Build(info)
+
+	picks := make(map[string]int)
+	for i := 0; i < 40; i++ {
+		res, err := p.Pick(balancer.PickInfo{})
+		assert.NoError(err, "an unexpected error occured in pick")
+
+		picks[res.SubConn.(*fakeSubConn).name]++
+	}
+
+	assert.Equal(30, picks["heavy"], "the number of picks should be equals")
+	assert.Equal(0, picks["light"], "the number of picks should be equals")
+	assert.Equal(10, picks["unset"], "the number of picks should be equals")
+}
+
+func TestPickerWithZeroWeights(t *testing.T) {
+	assert := assert.New(t)
+
+	first := &fakeSubConn{name: "first"}
+	second := &fakeSubConn{name: "second"}
+
+	info := base.PickerBuildInfo{
+		ReadySCs: map[balancer.SubConn]base.SubConnInfo{
+			first:  {Address: address("10.0.0.1:8080", 0)},
+			second: {Address: address("10.0.0.2:8080", 0)},
+		},
+	}
+
+	p := (&pickerBuilder{}).Build(info)
+
+	picks := make(map[string]int)
+	for i := 0; i < 10; i++ {
+		res, err := p.Pick(balancer.PickInfo{})
+		assert.NoError(err, "an unexpected error occured in pick")
+
+		picks[res.SubConn.(*fakeSubConn).name]++
+	}
+
+	assert.Equal(5, picks["first"], "the number of picks should be equals")
+	assert.Equal(5, picks["second"], "the number of picks should be equals")
+}
+
+func TestPickerWithoutReadyConnection(t *testing.T) {
+	assert := assert.New(t)
+
+	p := (&pickerBuilder{}).Build(base.PickerBuildInfo{})
+
+	_, err := p.Pick(balancer.PickInfo{})
+	assert.Equal(balancer.ErrNoSubConnAvailable, err, "the errors should be equals")
+}
+
+func address(addr string, weight uint32) grpcresolver.Address {
+	return resolver.NewAddress(addr, &resolver.Metadata{Weight: weight})
+}